@@ -1,12 +1,9 @@
 package alice
 
 import (
-	"context"
-
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/terra-money/core/app"
 	"github.com/terra-money/core/app/export/util"
-	wasmKeeper "github.com/terra-money/core/x/wasm/keeper"
 )
 
 var (
@@ -19,39 +16,15 @@ func ExportAlice(terra *app.TerraApp, b util.Blacklist) (util.SnapshotBalanceAgg
 	// register blacklist
 	b.RegisterAddress(util.DenomAUST, AliceaaUSTWrapper)
 
-	// iterate through cw20 users (force iterate since alice contract doesn't implement all_accounts)
-	// then get balance
+	// read cw20 balances from state, since alice contract doesn't implement all_accounts
 	// 1aaUST = 1aUST
 	ctx := util.PrepCtx(terra)
-	var balances = make(util.SnapshotBalanceMap)
-	if err := forceIterateAndFindWalletAndBalance(ctx, terra.WasmKeeper, AliceaaUSTWrapper, balances); err != nil {
+	balances := make(map[string]sdk.Int)
+	if err := util.GetCW20AccountsAndBalances(ctx, terra.WasmKeeper, AliceaaUSTWrapper, balances); err != nil {
 		return nil, err
 	}
 
 	var finalBalances = make(util.SnapshotBalanceAggregateMap)
-	for user, balance := range balances {
-		finalBalances.AppendOrAddBalance(user, balance)
-	}
-
+	finalBalances.Add(balances, util.DenomAUST)
 	return finalBalances, nil
 }
-
-func forceIterateAndFindWalletAndBalance(ctx context.Context, keeper wasmKeeper.Keeper, aaUST string, balances map[string]util.SnapshotBalance) error {
-	prefix := util.GeneratePrefix("balance")
-	addr, _ := sdk.AccAddressFromBech32(aaUST)
-
-	var bal string
-	keeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(ctx), addr, prefix, func(key, value []byte) bool {
-		util.MustUnmarshalTMJSON(value, &bal)
-
-		balInInt, _ := sdk.NewIntFromString(bal)
-		balances[string(key)] = util.SnapshotBalance{
-			Denom:   util.DenomAUST,
-			Balance: balInInt,
-		}
-
-		return false
-	})
-
-	return nil
-}
@@ -0,0 +1,9 @@
+package alice
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("alice", ExportAlice, nil).WithVersion(3))
+}
@@ -0,0 +1,231 @@
+// Package amm decomposes the LP positions of terraswap-like DEXes into the
+// assets of their pools.
+package amm
+
+import (
+	"fmt"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/terra-money/core/app/export/util"
+)
+
+const (
+	// stakingTolerance is how far the stakers of a staking contract, or the LP
+	// holders of a pair, may claim more or less LP tokens than it holds before
+	// their claims are normalized
+	stakingTolerance = 1000000
+	// defaultVaultTolerance is how far the users of a compounder vault may claim
+	// more or less LP tokens than it holds, unless its factory sets another
+	defaultVaultTolerance = 1000000
+)
+
+// Asset is a reserve of a pool.
+type Asset struct {
+	Info   util.AssetInfo `json:"info"`
+	Amount sdk.Int        `json:"amount"`
+}
+
+// Pair is a pool along with its LP token. Constant product pools have two
+// assets, stableswap pools two or more; both pay out withdrawals pro rata.
+type Pair struct {
+	Address    string  `json:"-"`
+	LpToken    string  `json:"-"`
+	Assets     []Asset `json:"assets"`
+	TotalShare sdk.Int `json:"total_share"`
+}
+
+// ShareOf returns the reserves lpAmount LP tokens of the pair withdraw.
+func (p Pair) ShareOf(lpAmount sdk.Int) []sdk.Int {
+	shares := make([]sdk.Int, len(p.Assets))
+	ratio := sdk.ZeroDec()
+	if !p.TotalShare.IsZero() {
+		ratio = sdk.NewDecFromInt(lpAmount).Quo(sdk.NewDecFromInt(p.TotalShare))
+	}
+	for i, a := range p.Assets {
+		shares[i] = ratio.MulInt(a.Amount).TruncateInt()
+	}
+	return shares
+}
+
+// Vaults are LP tokens held by contracts on behalf of their users, as
+// vault -> lp -> user -> amount. Compounder outputs have the same shape.
+type Vaults map[string]map[string]map[string]sdk.Int
+
+// Add records the holdings of users in vault for lp.
+func (v Vaults) Add(vault string, lp string, users util.BalanceMap) {
+	if v[vault] == nil {
+		v[vault] = make(map[string]map[string]sdk.Int)
+	}
+	v[vault][lp] = util.MergeMaps(v[vault][lp], users)
+}
+
+// Claim compares the LP tokens a vault holds with those its users claim.
+// Claims of a strict vault must be within Tolerance of the holdings; those of
+// other vaults further than Tolerance were scaled to the holdings.
+type Claim struct {
+	Vault      string
+	LpToken    string
+	Held       sdk.Int
+	Claimed    sdk.Int
+	Tolerance  int64
+	Strict     bool
+	Normalized bool
+}
+
+// Check records in audit whether the claims are within Tolerance of the
+// holdings: as an error for strict vaults, as a warning for normalized ones.
+func (c Claim) Check(audit *util.Audit) {
+	severity := util.SeverityWarn
+	if c.Strict {
+		severity = util.SeverityError
+	}
+	audit.Check(util.NewInvariant(fmt.Sprintf("vault %s lp %s claims", c.Vault, c.LpToken), severity, c.Tolerance), c.Held, c.Claimed)
+}
+
+// Decompose gives every LP holder of pairs their share of the snapshot assets
+// of the pool. holders maps each LP token to its holders and is rewritten in
+// place:
+//   - LP tokens held by a vault go to its users, after those of the vaults it
+//     stakes in. Users of compounded vaults must claim within vaultTolerance of
+//     the holdings; those of staked vaults are normalized
+//   - excluded holders are dropped, including those holding through a vault
+//   - LP tokens held by another pair go to the LP holders of that pair
+//
+// It returns the claims of every vault and pair holding LP tokens.
+func Decompose(pairs []Pair, holders map[string]util.BalanceMap, staked Vaults, compounded Vaults, vaultTolerance int64, excluded []string) (util.SnapshotBalanceAggregateMap, []Claim) {
+	var claims []Claim
+	vaults := make(Vaults)
+	for _, v := range []Vaults{staked, compounded} {
+		for vault, lps := range v {
+			for lp, users := range lps {
+				vaults.Add(vault, lp, users)
+			}
+		}
+	}
+	// vaults holding their LP tokens through another vault are spliced after it
+	spliced := make(map[string]bool)
+	var spliceVault func(vault string)
+	spliceVault = func(vault string) {
+		if spliced[vault] {
+			return
+		}
+		spliced[vault] = true
+		for _, outer := range vaults.sortedVaults() {
+			for lp := range vaults[vault] {
+				if _, ok := vaults[outer][lp][vault]; ok {
+					spliceVault(outer)
+				}
+			}
+		}
+		_, strict := compounded[vault]
+		for lp, users := range vaults[vault] {
+			tolerance := int64(stakingTolerance)
+			if strict {
+				tolerance = vaultTolerance
+			}
+			if c, ok := splice(holders[lp], vault, lp, users, tolerance, strict); ok {
+				claims = append(claims, c)
+			}
+		}
+	}
+	for _, vault := range vaults.sortedVaults() {
+		spliceVault(vault)
+	}
+	for _, lpHolders := range holders {
+		for _, addr := range excluded {
+			delete(lpHolders, addr)
+		}
+	}
+
+	// pairs holding the LP token of another pair are resolved first
+	done := make(map[string]bool)
+	var resolve func(p Pair)
+	resolve = func(p Pair) {
+		if done[p.Address] {
+			return
+		}
+		done[p.Address] = true
+		for _, outer := range pairs {
+			for _, a := range outer.Assets {
+				if a.Info.Token == nil || a.Info.Token.ContractAddr != p.LpToken || outer.TotalShare.IsZero() {
+					continue
+				}
+				resolve(outer)
+				users := make(util.BalanceMap)
+				for user, lpAmount := range holders[outer.LpToken] {
+					users[user] = sdk.NewDecFromInt(lpAmount).MulInt(a.Amount).QuoInt(outer.TotalShare).TruncateInt()
+				}
+				if c, ok := Splice(holders[p.LpToken], outer.Address, p.LpToken, users); ok {
+					claims = append(claims, c)
+				}
+			}
+		}
+	}
+	for _, p := range pairs {
+		resolve(p)
+	}
+
+	snapshot := make(util.SnapshotBalanceAggregateMap)
+	for _, p := range pairs {
+		for user, lpAmount := range holders[p.LpToken] {
+			for i, share := range p.ShareOf(lpAmount) {
+				denom, ok := p.Assets[i].Info.BalanceDenom()
+				if !ok || share.IsZero() {
+					continue
+				}
+				snapshot.AppendOrAddBalance(user, util.SnapshotBalance{Denom: denom, Balance: share})
+			}
+		}
+	}
+	return snapshot, claims
+}
+
+// Splice replaces the LP tokens a staking vault holds by the holdings of its
+// users, unless they claim none, normalizing claims further than
+// stakingTolerance from the holdings. It reports whether it did.
+func Splice(lpHolders util.BalanceMap, vault string, lp string, users util.BalanceMap) (Claim, bool) {
+	return splice(lpHolders, vault, lp, users, stakingTolerance, false)
+}
+
+// splice is Splice for a vault whose claims are normalized beyond tolerance,
+// or never if strict.
+func splice(lpHolders util.BalanceMap, vault string, lp string, users util.BalanceMap, tolerance int64, strict bool) (Claim, bool) {
+	held, ok := lpHolders[vault]
+	claimed := util.Sum(users)
+	if !ok || !held.IsPositive() || !claimed.IsPositive() {
+		return Claim{}, false
+	}
+	c := Claim{Vault: vault, LpToken: lp, Held: held, Claimed: claimed, Tolerance: tolerance, Strict: strict}
+	if !strict && !held.Sub(claimed).Abs().LT(sdk.NewInt(tolerance)) {
+		users, c.Normalized = scale(users, claimed, held), true
+	}
+	delete(lpHolders, vault)
+	for user, amount := range users {
+		if lpHolders[user].IsNil() {
+			lpHolders[user] = amount
+		} else {
+			lpHolders[user] = lpHolders[user].Add(amount)
+		}
+	}
+	return c, true
+}
+
+func scale(users util.BalanceMap, from sdk.Int, to sdk.Int) util.BalanceMap {
+	scaled := make(util.BalanceMap)
+	for user, amount := range users {
+		scaled[user] = sdk.NewDecFromInt(amount).MulInt(to).QuoInt(from).TruncateInt()
+	}
+	return scaled
+}
+
+// sortedVaults returns the vaults in order, so vaults resolve the same way on
+// every run.
+func (v Vaults) sortedVaults() []string {
+	vaults := make([]string, 0, len(v))
+	for vault := range v {
+		vaults = append(vaults, vault)
+	}
+	sort.Strings(vaults)
+	return vaults
+}
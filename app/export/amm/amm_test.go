@@ -0,0 +1,81 @@
+package amm
+
+import (
+	"encoding/json"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/terra-money/core/app/export/util"
+)
+
+func TestDecompose(t *testing.T) {
+	var lunaUst, stable Pair
+	if err := json.Unmarshal([]byte(`{"assets":[
+		{"info":{"native_token":{"denom":"uluna"}},"amount":"1000"},
+		{"info":{"native_token":{"denom":"uusd"}},"amount":"2000"}
+	],"total_share":"100"}`), &lunaUst); err != nil {
+		t.Fatal(err)
+	}
+	// a stableswap pool of three assets, one being the LP token of lunaUst
+	if err := json.Unmarshal([]byte(`{"assets":[
+		{"info":{"token":{"contract_addr":"lp-luna-ust"}},"amount":"10"},
+		{"info":{"native_token":{"denom":"uusd"}},"amount":"300"},
+		{"info":{"token":{"contract_addr":"unknown"}},"amount":"100"}
+	],"total_share":"10"}`), &stable); err != nil {
+		t.Fatal(err)
+	}
+	lunaUst.Address, lunaUst.LpToken = "pair-luna-ust", "lp-luna-ust"
+	stable.Address, stable.LpToken = "pair-stable", "lp-stable"
+
+	holders := map[string]util.BalanceMap{
+		"lp-luna-ust": {"alice": sdk.NewInt(40), "vault": sdk.NewInt(45), "staking": sdk.NewInt(5), "pair-stable": sdk.NewInt(10)},
+		"lp-stable":   {"dave": sdk.NewInt(10)},
+	}
+	staked, compounded := make(Vaults), make(Vaults)
+	staked.Add("vault", "lp-luna-ust", util.BalanceMap{"bob": sdk.NewInt(30), "compounder": sdk.NewInt(20)})
+	compounded.Add("compounder", "lp-luna-ust", util.BalanceMap{"carol": sdk.NewInt(20)})
+
+	snapshot, claims := Decompose([]Pair{stable, lunaUst}, holders, staked, compounded, defaultVaultTolerance, []string{"staking"})
+	if len(claims) != 3 {
+		t.Fatalf("expected claims of both vaults and the stable pair, got %+v", claims)
+	}
+	for _, c := range claims {
+		if c.Normalized || c.Strict != (c.Vault == "compounder") {
+			t.Fatalf("expected only the compounder to be strict and no normalization, got %+v", c)
+		}
+	}
+	for addr, expected := range map[string][2]int64{
+		"alice": {400, 800},
+		"bob":   {300, 600},
+		"carol": {200, 400},
+		"dave":  {100, 200 + 300},
+	} {
+		luna, ust := snapshot.GetAddrBalance(addr, util.DenomLUNA), snapshot.GetAddrBalance(addr, util.DenomUST)
+		if !luna.Equal(sdk.NewInt(expected[0])) || !ust.Equal(sdk.NewInt(expected[1])) {
+			t.Fatalf("%s: expected %v, got %s uluna %s uusd", addr, expected, luna, ust)
+		}
+	}
+	if len(snapshot) != 4 {
+		t.Fatalf("expected vaults, pairs and excluded holders to hold nothing, got %v", snapshot.SortedAddresses())
+	}
+
+	lpHolders := util.BalanceMap{"vault": sdk.NewInt(10000000)}
+	c, ok := Splice(lpHolders, "vault", "lp", util.BalanceMap{"x": sdk.NewInt(5000000), "y": sdk.NewInt(15000000)})
+	if !ok || !c.Normalized || !c.Claimed.Equal(sdk.NewInt(20000000)) {
+		t.Fatalf("expected claims to be normalized, got %+v", c)
+	}
+	if !lpHolders["x"].Equal(sdk.NewInt(2500000)) || !lpHolders["y"].Equal(sdk.NewInt(7500000)) || len(lpHolders) != 2 {
+		t.Fatalf("unexpected normalized holdings %v", lpHolders)
+	}
+
+	lpHolders = util.BalanceMap{"vault": sdk.NewInt(10000000)}
+	c, ok = splice(lpHolders, "vault", "lp", util.BalanceMap{"x": sdk.NewInt(20000000)}, defaultVaultTolerance, true)
+	if !ok || c.Normalized || !lpHolders["x"].Equal(sdk.NewInt(20000000)) {
+		t.Fatalf("expected strict claims to be spliced as claimed, got %+v %v", c, lpHolders)
+	}
+	report := util.NewAuditReport()
+	c.Check(report.For("test"))
+	if results := report.Results(); len(results) != 1 || results[0].Severity != util.SeverityError || results[0].Passed {
+		t.Fatalf("expected a failed error check, got %+v", results)
+	}
+}
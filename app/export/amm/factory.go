@@ -0,0 +1,379 @@
+package amm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/tendermint/tendermint/libs/log"
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/storage"
+	"github.com/terra-money/core/app/export/util"
+	wasmkeeper "github.com/terra-money/core/x/wasm/keeper"
+	wasmtypes "github.com/terra-money/core/x/wasm/types"
+)
+
+// Factory describes a terraswap-like DEX: where its pairs are listed and which
+// contracts hold LP tokens on behalf of users.
+type Factory struct {
+	// Name prefixes the log lines of the DEX
+	Name string
+	// Address is the factory contract, empty if every pair is listed in Pairs
+	Address string
+	// PairPrefix is the namespace the factory stores pairs under, "pair_info" if empty
+	PairPrefix string
+	// DecodePair decodes a stored pair, DecodePairInfo if nil
+	DecodePair func(value []byte) (pair string, lpToken string, err error)
+	// Pairs are pairs the factory does not list
+	Pairs []string
+	// Staking read the LP tokens staked on behalf of users, in the target
+	// pairs of every factory exported along
+	Staking []StakingFunc
+	// Generator is an astroport generator, whose user_info records staked LP tokens
+	Generator string
+	// Excluded are LP holders whose tokens go to no one
+	Excluded []string
+	// VaultTolerance is how far the users of compounder vaults may claim more or
+	// less LP tokens of the factory than the vaults hold, 1000000 if zero
+	VaultTolerance int64
+}
+
+// StakingFunc returns the LP tokens of pairs held by contracts on behalf of their users.
+type StakingFunc func(ctx context.Context, keeper wasmkeeper.Keeper, qs wasmtypes.QueryServer, pairs []Pair) (Vaults, error)
+
+var (
+	stakingRewards = storage.NewBucket(storage.KeyCanonical, "reward")
+	generatorUsers = storage.NewMap("user_info", storage.KeyAddr, storage.KeyAddr)
+)
+
+// DecodePairInfo decodes the PairInfo of terraswap-like factories, which store
+// canonical addresses.
+func DecodePairInfo(value []byte) (string, string, error) {
+	var info struct {
+		ContractAddr   []byte `json:"contract_addr"`
+		LiquidityToken []byte `json:"liquidity_token"`
+	}
+	if err := json.Unmarshal(value, &info); err != nil {
+		return "", "", err
+	}
+	var lpToken string
+	if len(info.LiquidityToken) != 0 {
+		lpToken = sdk.AccAddress(info.LiquidityToken).String()
+	}
+	return sdk.AccAddress(info.ContractAddr).String(), lpToken, nil
+}
+
+// PairAddress decodes factories storing the address of each pair only, such
+// as astroport.
+func PairAddress(value []byte) (string, string, error) {
+	var pair string
+	err := json.Unmarshal(value, &pair)
+	return pair, "", err
+}
+
+// QueryPair queries the pool of a pair, and its LP token unless known.
+func QueryPair(ctx context.Context, qs wasmtypes.QueryServer, addr string, lpToken string) (Pair, error) {
+	var p Pair
+	if err := util.ContractQuery(ctx, qs, &wasmtypes.QueryContractStoreRequest{
+		ContractAddress: addr,
+		QueryMsg:        []byte("{\"pool\":{}}"),
+	}, &p); err != nil {
+		return Pair{}, err
+	}
+	if lpToken == "" {
+		var info struct {
+			LiquidityToken string `json:"liquidity_token"`
+		}
+		if err := util.ContractQuery(ctx, qs, &wasmtypes.QueryContractStoreRequest{
+			ContractAddress: addr,
+			QueryMsg:        []byte("{\"pair\":{}}"),
+		}, &info); err != nil {
+			return Pair{}, err
+		}
+		lpToken = info.LiquidityToken
+	}
+	p.Address, p.LpToken = addr, lpToken
+	return p, nil
+}
+
+// Targets returns the pairs holding a snapshot asset, directly or through the
+// LP token of another target.
+func Targets(pairs []Pair) []Pair {
+	lps := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, p := range pairs {
+			if lps[p.LpToken] {
+				continue
+			}
+			for _, a := range p.Assets {
+				if _, ok := a.Info.BalanceDenom(); ok || (a.Info.Token != nil && lps[a.Info.Token.ContractAddr]) {
+					lps[p.LpToken], changed = true, true
+					break
+				}
+			}
+		}
+	}
+	var targets []Pair
+	for _, p := range pairs {
+		if lps[p.LpToken] {
+			targets = append(targets, p)
+		}
+	}
+	return targets
+}
+
+// Export decomposes the LP tokens of every target pair of the factories into
+// their assets. Every pair is blacklisted. vaults are LP tokens held by
+// compounders, resolved along with the staking contracts of the factories.
+// The LP supplies and vault claims are checked in audit: compounders fail the
+// audit when their users' claims do not match, staking contracts are
+// normalized with a warning.
+func Export(app *terra.TerraApp, bl util.Blacklist, vaults Vaults, audit *util.Audit, factories ...Factory) (util.SnapshotBalanceAggregateMap, error) {
+	ctx := util.PrepCtx(app)
+	qs := util.PrepWasmQueryServer(app)
+	keeper := app.WasmKeeper
+	logger := app.Logger()
+
+	var pairs []Pair
+	seen := make(map[string]bool)
+	for _, f := range factories {
+		logger.Info(fmt.Sprintf("... Retrieving %s pools", f.Name))
+		fp, err := f.pairs(ctx, keeper, qs, bl, audit, logger)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range fp {
+			if !seen[p.Address] {
+				seen[p.Address] = true
+				pairs = append(pairs, p)
+			}
+		}
+	}
+	pairs = Targets(pairs)
+	logger.Info(fmt.Sprintf("...... pool count: %d", len(pairs)))
+
+	logger.Info("... Getting LP holders")
+	holders := make(map[string]util.BalanceMap)
+	for i, p := range pairs {
+		if i > 0 && i%100 == 0 {
+			logger.Info(fmt.Sprintf("...... processed %d", i))
+		}
+		balances := make(util.BalanceMap)
+		if err := util.GetCW20AccountsAndBalances(ctx, keeper, p.LpToken, balances); err != nil {
+			return nil, fmt.Errorf("failed to iterate over LP token owners of %s: %v", p.LpToken, err)
+		}
+		holders[p.LpToken] = balances
+
+		supply, err := util.GetCW20TotalSupply(ctx, qs, p.LpToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query the supply of LP token %s: %v", p.LpToken, err)
+		}
+		audit.Check(util.NewInvariant(fmt.Sprintf("lp %s supply", p.LpToken), util.SeverityWarn, 2000000), supply, util.Sum(balances))
+	}
+
+	logger.Info("... Resolving staking ownership")
+	staked, compounded := make(Vaults), make(Vaults)
+	for vault, lps := range vaults {
+		for lp, users := range lps {
+			compounded.Add(vault, lp, users)
+		}
+	}
+	var excluded []string
+	tolerance := int64(defaultVaultTolerance)
+	for _, f := range factories {
+		if f.VaultTolerance > tolerance {
+			tolerance = f.VaultTolerance
+		}
+		for _, stake := range f.staking() {
+			vaults, err := stake(ctx, keeper, qs, pairs)
+			if err != nil {
+				return nil, err
+			}
+			for vault, lps := range vaults {
+				for lp, users := range lps {
+					staked.Add(vault, lp, users)
+				}
+			}
+		}
+		excluded = append(excluded, f.Excluded...)
+	}
+
+	snapshot, claims := Decompose(pairs, holders, staked, compounded, tolerance, excluded)
+	for _, c := range claims {
+		if c.Normalized {
+			logger.Info(fmt.Sprintf("...... vault %s holds %s of lp %s, its users claim %s: normalized", c.Vault, c.Held, c.LpToken, c.Claimed))
+		}
+		c.Check(audit)
+	}
+	return snapshot, nil
+}
+
+// pairs lists and queries the pairs of the factory, skipping those that cannot
+// be decoded or queried, or hold no liquidity. Undecodable entries are counted
+// in audit.
+func (f Factory) pairs(ctx context.Context, keeper wasmkeeper.Keeper, qs wasmtypes.QueryServer, bl util.Blacklist, audit *util.Audit, logger log.Logger) ([]Pair, error) {
+	var addrs []string
+	lpTokens := make(map[string]string)
+	if f.Address != "" {
+		store, err := storage.NewContractStore(ctx, keeper, f.Address)
+		if err != nil {
+			return nil, err
+		}
+		decode, prefix := f.DecodePair, f.PairPrefix
+		if decode == nil {
+			decode = DecodePairInfo
+		}
+		if prefix == "" {
+			prefix = "pair_info"
+		}
+		undecodable := 0
+		store.IterateWithPrefix(util.GeneratePrefix(prefix), func(key, value []byte) bool {
+			pair, lpToken, err := decode(value)
+			if err != nil {
+				logger.Info(fmt.Sprintf("...... %s: undecodable pair %x, skipping: %v", f.Name, key, err))
+				undecodable++
+				return false
+			}
+			addrs = append(addrs, pair)
+			lpTokens[pair] = lpToken
+			return false
+		})
+		audit.Check(util.NewInvariant(fmt.Sprintf("%s undecodable pairs", f.Name), util.SeverityWarn, 1), sdk.ZeroInt(), sdk.NewInt(int64(undecodable)))
+	}
+	addrs = append(addrs, f.Pairs...)
+
+	var pairs []Pair
+	for _, addr := range addrs {
+		// register all pairs as blacklist.
+		util.RegisterPool(bl, addr)
+
+		p, err := QueryPair(ctx, qs, addr, lpTokens[addr])
+		if err != nil {
+			logger.Info(fmt.Sprintf("...... %s: irregular pair, skipping: %s", f.Name, addr))
+			continue
+		}
+		if p.TotalShare.IsNil() || p.TotalShare.IsZero() {
+			continue
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, nil
+}
+
+func (f Factory) staking() []StakingFunc {
+	if f.Generator == "" {
+		return f.Staking
+	}
+	return append(append([]StakingFunc{}, f.Staking...), Generator(f.Generator))
+}
+
+// StakingContracts resolves terraswap-style staking contracts, which stake the
+// LP token named in their init message.
+func StakingContracts(contracts ...string) StakingFunc {
+	return func(ctx context.Context, keeper wasmkeeper.Keeper, _ wasmtypes.QueryServer, pairs []Pair) (Vaults, error) {
+		lps := lpTokens(pairs)
+		vaults := make(Vaults)
+		for _, contract := range contracts {
+			info, err := keeper.GetContractInfo(sdk.UnwrapSDKContext(ctx), util.ToAddress(contract))
+			if err != nil {
+				return nil, err
+			}
+			var initMsg struct {
+				LpToken      string `json:"lp_token"`
+				StakingToken string `json:"staking_token"`
+			}
+			if err = json.Unmarshal(info.InitMsg, &initMsg); err != nil {
+				return nil, err
+			}
+			lp := initMsg.StakingToken
+			if lp == "" {
+				lp = initMsg.LpToken
+			}
+			if !lps[lp] {
+				continue
+			}
+			stakers, err := Stakers(ctx, keeper, contract)
+			if err != nil {
+				return nil, fmt.Errorf("staking %s: %v", contract, err)
+			}
+			vaults.Add(contract, lp, stakers)
+		}
+		return vaults, nil
+	}
+}
+
+// Stakers reads the bond_amount of every staker of a terraswap-style staking
+// contract. Tokens staked before the staking token was migrated are ignored.
+func Stakers(ctx context.Context, keeper wasmkeeper.Keeper, contract string) (util.BalanceMap, error) {
+	store, err := storage.NewContractStore(ctx, keeper, contract)
+	if err != nil {
+		return nil, err
+	}
+	stakers := make(util.BalanceMap)
+	err = stakingRewards.Range(store, func(keys storage.Keys, value storage.Value) error {
+		staker, err := keys[0].Addr()
+		if err != nil {
+			return err
+		}
+		var reward struct {
+			Amount              sdk.Int `json:"bond_amount"`
+			StakingTokenVersion int     `json:"staking_token_version"`
+		}
+		if err := value.JSON(&reward); err != nil {
+			return err
+		}
+		if reward.StakingTokenVersion == 0 && !reward.Amount.IsNil() {
+			stakers[staker] = reward.Amount
+		}
+		return nil
+	})
+	return stakers, err
+}
+
+// Generator resolves an astroport generator, which records the LP tokens
+// staked by each user.
+func Generator(generator string) StakingFunc {
+	return func(ctx context.Context, keeper wasmkeeper.Keeper, _ wasmtypes.QueryServer, pairs []Pair) (Vaults, error) {
+		store, err := storage.NewContractStore(ctx, keeper, generator)
+		if err != nil {
+			return nil, err
+		}
+		lps := lpTokens(pairs)
+		staked := make(map[string]util.BalanceMap)
+		err = generatorUsers.Range(store, func(keys storage.Keys, value storage.Value) error {
+			lp, user := keys[0].String(), keys[1].String()
+			if !lps[lp] {
+				return nil
+			}
+			var info struct {
+				Amount sdk.Int `json:"amount"`
+			}
+			if err := value.JSON(&info); err != nil {
+				return err
+			}
+			if staked[lp] == nil {
+				staked[lp] = make(util.BalanceMap)
+			}
+			staked[lp][user] = info.Amount
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("generator %s: %v", generator, err)
+		}
+		vaults := make(Vaults)
+		for lp, users := range staked {
+			vaults.Add(generator, lp, users)
+		}
+		return vaults, nil
+	}
+}
+
+func lpTokens(pairs []Pair) map[string]bool {
+	lps := make(map[string]bool)
+	for _, p := range pairs {
+		lps[p.LpToken] = true
+	}
+	return lps
+}
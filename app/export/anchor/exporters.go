@@ -0,0 +1,10 @@
+package anchor
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("anchor", ExportAnchorDeposit, nil).WithVersion(2))
+	util.RegisterExporter(util.NewSBAExporter("anchor-bluna", ExportbLUNA, nil))
+}
@@ -0,0 +1,9 @@
+package angel
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("angel", ExportEndowments, nil))
+}
@@ -0,0 +1,10 @@
+package aperture
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("aperture-pre", ExportApertureVaultsPreAttack, nil).OnlyFor(util.Snapshot(util.PreAttack)))
+	util.RegisterExporter(util.NewSBAExporter("aperture-post", ExportApertureVaultsPostAttack, nil).OnlyFor(util.Snapshot(util.PostAttack)))
+}
@@ -0,0 +1,9 @@
+package apollo
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewCompounderExporter("apollo", ExportApolloVaultLPs, nil))
+}
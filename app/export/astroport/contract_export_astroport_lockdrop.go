@@ -30,35 +30,9 @@ func ExportAstroportLockdrop(app *app.TerraApp, snapshot util.SnapshotBalanceAgg
 	qs := util.PrepWasmQueryServer(app)
 	keeper := app.WasmKeeper
 
-	// 1. iterate all pairs & get lp token total share. key is in astroport pair
-	var pm = make(poolMap)
-
-	pairPrefix := util.GeneratePrefix("pair_info")
-	factory, _ := sdk.AccAddressFromBech32(AddressAstroportFactory)
 	lockdrop, _ := sdk.AccAddressFromBech32(AddressAstroportLockdrop)
 
-	var pairAddr string
-	keeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(ctx), factory, pairPrefix, func(key, value []byte) bool {
-		var p pool
-		util.MustUnmarshalTMJSON(value, &pairAddr)
-
-		if err := util.ContractQuery(ctx, qs, &wasmtypes.QueryContractStoreRequest{
-			ContractAddress: pairAddr,
-			QueryMsg:        []byte("{\"pool\":{}}"),
-		}, &p); err != nil {
-			panic(fmt.Errorf("unable to... %v", err))
-		}
-
-		// filter out those that have aUST/UST/LUNA
-		// technically astroport doesn't have aUST pool but maybe!
-		if isTargetPool(&p) {
-			pm[pairAddr] = p
-		}
-
-		return false
-	})
-
-	// 2. get pools (and get astroport lp token addr then astroport pair) - key is terraswap lp address
+	// 1. get pools (and get astroport lp token addr then astroport pair) - key is terraswap lp address
 	var liquidityPools = make(map[string]poolInfo)
 	var lpLockedInGenerator = make(map[string]sdk.Int)
 	poolsPrefix := util.GeneratePrefix("LiquidityPools")
@@ -81,7 +55,7 @@ func ExportAstroportLockdrop(app *app.TerraApp, snapshot util.SnapshotBalanceAgg
 		return false
 	})
 
-	// 4. Iterate over all lockdrop pos
+	// 2. Iterate over all lockdrop pos
 	prefix := util.GeneratePrefix("lockup_position")
 	var lockupInfo struct {
 		LPUnitsLocked          sdk.Int `json:"lp_units_locked"`
@@ -122,15 +96,3 @@ func ExportAstroportLockdrop(app *app.TerraApp, snapshot util.SnapshotBalanceAgg
 
 	return lpContractHoldings, nil
 }
-
-func getShareInAssets(p pool, lpAmount sdk.Int, totalShare sdk.Int) [2]sdk.Int {
-	shareRatio := sdk.ZeroDec()
-	if !totalShare.IsZero() {
-		shareRatio = sdk.NewDecFromInt(lpAmount).Quo(sdk.NewDecFromInt(totalShare))
-	}
-
-	return [2]sdk.Int{
-		shareRatio.MulInt(p.Assets[0].Amount).TruncateInt(),
-		shareRatio.MulInt(p.Assets[1].Amount).TruncateInt(),
-	}
-}
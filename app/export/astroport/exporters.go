@@ -0,0 +1,10 @@
+package astroport
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewCompounderExporter("astro-lockdrop", ExportAstroportLockdrop, nil).WithVersion(3))
+	util.RegisterExporter(util.NewAuditedExporter("astroport", util.KindDEX, ExportAstroportLP).WithVersion(5))
+}
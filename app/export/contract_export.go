@@ -2,60 +2,200 @@ package app
 
 import (
 	"fmt"
-
-	"github.com/terra-money/core/app/export/alice"
-	"github.com/terra-money/core/app/export/anchor"
-	"github.com/terra-money/core/app/export/angel"
-	"github.com/terra-money/core/app/export/aperture"
-	"github.com/terra-money/core/app/export/apollo"
-	"github.com/terra-money/core/app/export/astroport"
-	"github.com/terra-money/core/app/export/edge"
-	"github.com/terra-money/core/app/export/generic"
-	"github.com/terra-money/core/app/export/glow"
-	"github.com/terra-money/core/app/export/ink"
-	"github.com/terra-money/core/app/export/kinetic"
-	"github.com/terra-money/core/app/export/kujira"
-	"github.com/terra-money/core/app/export/lido"
-	"github.com/terra-money/core/app/export/loop"
-	"github.com/terra-money/core/app/export/native"
-	"github.com/terra-money/core/app/export/nebula"
-	"github.com/terra-money/core/app/export/nexus"
-	"github.com/terra-money/core/app/export/oneplanet"
-	"github.com/terra-money/core/app/export/prism"
-	"github.com/terra-money/core/app/export/pylon"
-	"github.com/terra-money/core/app/export/randomearth"
-	"github.com/terra-money/core/app/export/spectrum"
-	"github.com/terra-money/core/app/export/stader"
-	"github.com/terra-money/core/app/export/starflet"
-	"github.com/terra-money/core/app/export/starterra"
-	"github.com/terra-money/core/app/export/steak"
-	"github.com/terra-money/core/app/export/suberra"
-	"github.com/terra-money/core/app/export/terrafloki"
-	"github.com/terra-money/core/app/export/terraswap"
-	"github.com/terra-money/core/app/export/tfm"
-	"github.com/terra-money/core/app/export/whitewhale"
+	"os"
+	"path/filepath"
+	"sort"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
-	"github.com/cosmos/cosmos-sdk/x/bank/types"
 	terra "github.com/terra-money/core/app"
-	"github.com/terra-money/core/app/export/mars"
-	"github.com/terra-money/core/app/export/mirror"
+	"github.com/terra-money/core/app/export/generic"
+	"github.com/terra-money/core/app/export/generic/common"
 	"github.com/terra-money/core/app/export/util"
+
+	// protocol exporters register themselves with util.RegisterExporter
+	_ "github.com/terra-money/core/app/export/alice"
+	_ "github.com/terra-money/core/app/export/anchor"
+	_ "github.com/terra-money/core/app/export/angel"
+	_ "github.com/terra-money/core/app/export/aperture"
+	_ "github.com/terra-money/core/app/export/apollo"
+	_ "github.com/terra-money/core/app/export/astroport"
+	_ "github.com/terra-money/core/app/export/edge"
+	_ "github.com/terra-money/core/app/export/glow"
+	_ "github.com/terra-money/core/app/export/ink"
+	_ "github.com/terra-money/core/app/export/kinetic"
+	_ "github.com/terra-money/core/app/export/kujira"
+	_ "github.com/terra-money/core/app/export/lido"
+	_ "github.com/terra-money/core/app/export/loop"
+	_ "github.com/terra-money/core/app/export/mars"
+	_ "github.com/terra-money/core/app/export/mirror"
+	_ "github.com/terra-money/core/app/export/native"
+	_ "github.com/terra-money/core/app/export/nebula"
+	_ "github.com/terra-money/core/app/export/nexus"
+	_ "github.com/terra-money/core/app/export/oneplanet"
+	_ "github.com/terra-money/core/app/export/prism"
+	_ "github.com/terra-money/core/app/export/pylon"
+	_ "github.com/terra-money/core/app/export/randomearth"
+	_ "github.com/terra-money/core/app/export/spectrum"
+	_ "github.com/terra-money/core/app/export/stader"
+	_ "github.com/terra-money/core/app/export/starflet"
+	_ "github.com/terra-money/core/app/export/starterra"
+	_ "github.com/terra-money/core/app/export/steak"
+	_ "github.com/terra-money/core/app/export/suberra"
+	_ "github.com/terra-money/core/app/export/terrafloki"
+	_ "github.com/terra-money/core/app/export/terraswap"
+	_ "github.com/terra-money/core/app/export/tfm"
+	_ "github.com/terra-money/core/app/export/tokens"
+	_ "github.com/terra-money/core/app/export/whitewhale"
 )
 
-func ExportContracts(app *terra.TerraApp) []types.Balance {
-	// var err error
-	var snapshotType util.Snapshot
-	if app.LastBlockHeight() == 7544910 {
-		snapshotType = util.Snapshot(util.PreAttack)
-	} else {
-		snapshotType = util.Snapshot(util.PostAttack)
+// ProtocolFilter limits the protocol exporters that run. Resolvers always run.
+type ProtocolFilter struct {
+	// Include, if set, lists the only protocol exporters to run
+	Include []string
+	// Exclude lists protocol exporters to skip
+	Exclude []string
+}
+
+func (f ProtocolFilter) validate() error {
+	for _, name := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, ok := util.GetExporter(name); !ok {
+			return fmt.Errorf("unknown exporter %s", name)
+		}
+	}
+	return nil
+}
+
+func (f ProtocolFilter) allows(e util.Exporter) bool {
+	if !e.Kind().IsProtocol() {
+		return true
+	}
+	for _, name := range f.Exclude {
+		if name == e.Name() {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, name := range f.Include {
+		if name == e.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildExportPlan resolves the registered exporters enabled by the profile and
+// passing filter into an ordered plan, ending with the merge and finalize stages.
+func BuildExportPlan(profile Profile, filter ProtocolFilter) (*util.Plan, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	var stages []util.Exporter
+	for _, e := range util.Exporters() {
+		if profile.enables(e) && filter.allows(e) {
+			stages = append(stages, e)
+		}
+	}
+
+	merge := util.NewExporter("merge", util.KindMerge, mergeProtocols).
+		Produces(util.ResourceMergedSnapshot)
+	for _, e := range stages {
+		if e.Kind() == util.KindSBA || e.Kind() == util.KindDEX {
+			merge.Consumes(util.SnapshotOf(e.Name()))
+		}
 	}
 
-	bl := NewBlacklist()
+	// every derivative has to be resolved to LUNA before the final audit
+	finalize := util.NewExporter("finalize", util.KindFinalize, func(app *terra.TerraApp, bl util.Blacklist, state *util.ExportState) (util.ExportOutput, error) {
+		return finalizeSnapshot(app, bl, state, profile)
+	}).
+		Consumes(util.ResourceMergedSnapshot).
+		Produces("final snapshot")
+	for _, a := range util.Assets() {
+		if a.Resolved() {
+			finalize.Consumes(util.DenomConversion(a.Denom, a.Underlying))
+		}
+	}
+	if profile.AUSTToUST == AUSTConvert {
+		finalize.Produces(util.DenomConversion(util.DenomAUST, util.DenomUST))
+	}
+
+	return util.BuildPlan(profile.SnapshotType, append(stages, merge, finalize))
+}
+
+// Options configures a contract export run.
+type Options struct {
+	// Profile describes the snapshot; it must be taken at the app's height
+	Profile Profile
+	// Filter limits the protocol exporters that run
+	Filter ProtocolFilter
+	// Workers is the number of protocol exporters run concurrently
+	Workers int
+	// Refresh names exporters whose cached outputs are ignored
+	Refresh []string
+	// RefreshAll ignores every cached output
+	RefreshAll bool
+	// Provenance records the sources of every balance in the saved snapshots
+	Provenance bool
+}
+
+// OpenCache opens the exporter cache at the profile height. Entries are also
+// keyed on the profile settings exporters read besides the outputs they
+// consume: nexus reads the extra blacklist, provenance adds sources to every
+// balance and tracked tokens change which pools are decomposed.
+func OpenCache(profile Profile, provenance bool, refresh []string, refreshAll bool) (*util.Cache, error) {
+	cache, err := util.OpenCache(profile.Height, refresh, refreshAll)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make(map[string]interface{})
+	if len(profile.ExtraBlacklist) > 0 {
+		inputs["extra_blacklist"] = util.Blacklist(profile.ExtraBlacklist)
+	}
+	if provenance {
+		inputs["provenance"] = true
+	}
+	if len(profile.Tokens) > 0 {
+		tokens := append([]string{}, profile.Tokens...)
+		sort.Strings(tokens)
+		inputs["tokens"] = tokens
+	}
+	for name, v := range inputs {
+		if err := cache.AddInput(name, v); err != nil {
+			return nil, err
+		}
+	}
+	return cache, nil
+}
+
+// ExportContracts runs the export plan described by opts and returns the final
+// snapshot.
+func ExportContracts(app *terra.TerraApp, opts Options) util.SnapshotBalanceAggregateMap {
+	profile := opts.Profile
+	if profile.Height != app.LastBlockHeight() {
+		panic(fmt.Errorf("profile is for height %d, app is at %d", profile.Height, app.LastBlockHeight()))
+	}
+	check(profile.Validate())
+	check(util.TrackTokens(profile.Tokens...))
+	if blockTime, _ := profile.blockTime(); !blockTime.IsZero() {
+		util.SetBlockTime(profile.Height, blockTime)
+	}
+	if opts.Provenance {
+		util.EnableProvenance()
+	}
+	snapshotType := profile.SnapshotType
+
+	bl := NewBlacklist(profile)
+	util.TrackConservation(bl)
 	logger := app.Logger()
 	logger.Info(fmt.Sprintf("Exporting Contracts @ %d - %s", app.LastBlockHeight(), snapshotType))
 
+	plan, err := BuildExportPlan(profile, opts.Filter)
+	check(err)
+	cache, err := OpenCache(profile, opts.Provenance, opts.Refresh, opts.RefreshAll)
+	check(err)
+
 	// a global holder for all contracts and their contractInfo
 	vestingSs, contractMap, err := generic.ExportVestingContracts(app, bl)
 	if err != nil {
@@ -63,266 +203,306 @@ func ExportContracts(app *terra.TerraApp) []types.Balance {
 	}
 	util.SmartContractsAddresses = contractMap
 
-	// // Export anchor
-	aUST := checkWithSs(util.CachedSBA(anchor.ExportAnchorDeposit, "anchor", app, bl))
-	bLunaInCustody := checkWithSs(util.CachedSBA(anchor.ExportbLUNA, "anchor-bluna", app, bl))
-
-	singleStakingSnapshot := make(util.SnapshotBalanceAggregateMap)
-	// Export Compounders
-	compoundedLps, err := exportCompounders(app, snapshotType, singleStakingSnapshot)
-	if err != nil {
-		panic(err)
+	state := util.NewExportState(snapshotType)
+	state.Folder = cache.Folder()
+	vestingSs.Attribute("vesting")
+	check(util.SaveToFile(app, vestingSs, "vesting"))
+	state.SetOutput("vesting", util.ExportOutput{Snapshot: vestingSs})
+
+	err = runPlan(app, bl, state, plan, cache, opts.Workers)
+	if err == nil {
+		audit := state.Audit.For("reconcile")
+		if reconciled, rerr := reconcile(app, plan, audit); rerr != nil {
+			audit.Fail(rerr)
+		} else {
+			check(writeReconciliation(cache.Folder(), reconciled))
+		}
 	}
-	check(mirror.AuditCompounders(app, compoundedLps))
-
-	// Export DEXs
-	astroportSnapshot := checkWithSs(util.CachedDex(astroport.ExportAstroportLP, "astroport", app, bl, compoundedLps))
-	terraswapSnapshot := checkWithSs(util.CachedDex(terraswap.ExportTerraswapLiquidity, "terraswap", app, bl, compoundedLps))
-	loopSnapshot := checkWithSs(util.CachedSBA(loop.ExportLoopLP, "loop", app, bl))
-
-	// Export Vaults
-	suberraSs := checkWithSs(util.CachedSBA(suberra.ExportSuberra, "suberra", app, bl))
-	check(suberra.Audit(app, suberraSs))
-	whiteWhaleSs := checkWithSs(util.CachedSBA(whitewhale.ExportWhiteWhaleVaults, "whitewhale", app, bl))
-	check(whitewhale.Audit(app, whiteWhaleSs))
-	kujiraSs := checkWithSs(util.CachedSBA(kujira.ExportKujiraVault, "kujira", app, bl))
-	check(kujira.Audit(app, kujiraSs))
-	prismSs := checkWithSs(util.CachedSBA(prism.ExportContract, "prism", app, bl))
-	check(prism.Audit(app, prismSs))
-	prismLoSs := checkWithSs(util.CachedSBA(prism.ExportLimitOrderContract, "prism-limit-order", app, bl))
-	check(prism.AuditLOs(app, prismLoSs))
-	var apertureSs util.SnapshotBalanceAggregateMap
-	if snapshotType == util.Snapshot(util.PreAttack) {
-		apertureSs = checkWithSs(util.CachedSBA(aperture.ExportApertureVaultsPreAttack, "aperture-pre", app, bl))
-	} else {
-		apertureSs = checkWithSs(util.CachedSBA(aperture.ExportApertureVaultsPostAttack, "aperture-post", app, bl))
-	}
-
-	edgeSs := checkWithSs(util.CachedSBA(edge.ExportContract, "edge", app, bl))
-	check(edge.Audit(app, edgeSs))
-	mirrorSs := checkWithSs(util.CachedSBA(mirror.ExportMirrorCdps, "mirror-cdp", app, bl))
-	check(mirror.AuditCdps(app, mirrorSs))
-	mirrorLoSs := checkWithSs(util.CachedSBA(mirror.ExportLimitOrderContract, "mirror-limit-order", app, bl))
-	check(mirror.AuditLOs(app, mirrorLoSs))
-	inkSs := checkWithSs(util.CachedSBA(ink.ExportContract, "ink", app, bl))
-	lunaXSs := checkWithSs(util.CachedSBA(stader.ExportLunaX, "stader", app, bl))
-	staderPoolSs := checkWithSs(util.CachedSBA(stader.ExportPools, "stader-pools", app, bl))
-	staderStakeSs := checkWithSs(util.CachedSBA(stader.ExportStakePlus, "stader-stake-plus", app, bl))
-	staderVaultSs := checkWithSs(util.CachedSBA(stader.ExportVaults, "stader-vaults", app, bl))
-	tfmFarmsSs := checkWithSs(util.CachedSBA(tfm.ExportTfmFarms, "tfm-farm", app, bl))
-	tfmLpsSs := checkWithSs(util.CachedSBA(tfm.ExportTfmLiquidity, "tfm-lp", app, bl))
-	angelSs := checkWithSs(util.CachedSBA(angel.ExportEndowments, "angel", app, bl))
-	randomEarthSs := checkWithSs(util.CachedSBA(randomearth.ExportSettlements, "radomearth", app, bl))
-	starTerraSs := checkWithSs(util.CachedSBA(starterra.ExportIDO, "starterra", app, bl))
-	check(starterra.Audit(app, starTerraSs))
-	starfletSs := checkWithSs(util.CachedSBA(starflet.ExportArbitrageAUST, "starflet", app, bl))
-	pylonSs := checkWithSs(util.CachedSBA(pylon.ExportContract, "pylon", app, bl))
-	marsSs := checkWithSs(util.CachedSBA(mars.ExportContract, "mars", app, bl))
-	check(mars.Audit(app, marsSs))
-
-	// Export miscellaneous
-	flokiSs := checkWithSs(util.CachedSBA(terrafloki.ExportTerraFloki, "floki", app, bl))
-	flokiRefundsSs := checkWithSs(util.CachedSBA(terrafloki.ExportFlokiRefunds, "floki-refunds", app, bl))
-	nebulaSs := checkWithSs(util.CachedSBA(nebula.ExportNebulaCommunityFund, "nebula", app, bl))
-	aliceSs := checkWithSs(util.CachedSBA(alice.ExportAlice, "alice", app, bl))
-	kineticSs := checkWithSs(util.CachedSBA(kinetic.ExportKinetic, "kinetic", app, bl))
-	steakSs := checkWithSs(util.CachedSBA(steak.ExportSteak, "steak", app, bl))
-	onePlanetSs := checkWithSs(util.CachedSBA(oneplanet.ExportHoldings, "oneplanet", app, bl))
-	nexusSs, err := nexus.ExportNexus(app, astroportSnapshot, bl)
-	glowSs := checkWithSs(util.CachedSBA(glow.ExportContract, "glow", app, bl))
-	util.SaveToFile(app, nexusSs, "nexus")
+	check(util.AuditCW20Reports(state, state.Audit.For("cw20"), cache.Folder()))
+	check(state.Audit.Write(cache.Folder()))
 	check(err)
+	check(state.Audit.Err())
 
-	snapshot := util.MergeSnapshots(
-		// DEX
-		astroportSnapshot, terraswapSnapshot, loopSnapshot,
-		suberraSs, whiteWhaleSs, kujiraSs, prismSs,
-		prismLoSs, apertureSs, edgeSs, mirrorSs,
-		mirrorLoSs, inkSs, lunaXSs, staderPoolSs,
-		staderStakeSs, staderVaultSs, angelSs,
-		randomEarthSs, starfletSs, flokiSs,
-		flokiRefundsSs, nebulaSs, aliceSs, kineticSs,
-		steakSs, nexusSs, marsSs,
-		pylonSs, onePlanetSs, glowSs,
-    tfmFarmsSs, tfmLpsSs,
-		// anchor
-		aUST,
-		bLunaInCustody,
-	)
-
-	bondedLuna := checkWithSs(util.CachedSBA(native.ExportAllBondedLuna, "bonded-luna", app, bl))
+	return state.Snapshot
+}
+
+func NewBlacklist(profile Profile) util.Blacklist {
+	bl := util.Blacklist{
+		util.DenomUST:  []string{},
+		util.DenomLUNA: []string{},
+		util.DenomAUST: []string{},
+	}
+	// bonding and unbonding pools
 	bl.RegisterAddress(util.DenomLUNA, "terra1fl48vsnmsdzcv85q5d2q4z5ajdha8yu3nln0mh")
 	bl.RegisterAddress(util.DenomLUNA, "terra1tygms3xhhs3yv487phx3dw4a95jn7t7l8l07dr")
-	nativeBalances := checkWithSs(util.CachedSBA(native.ExportAllNativeBalances, "native-balance", app, bl))
-
-	snapshot = util.MergeSnapshots(snapshot, bondedLuna, nativeBalances, vestingSs)
-	snapshot.ApplyBlackList(bl)
-
-	util.SaveToFile(app, snapshot, "after-protocols")
-
-	// Export Liquid Staking
-	check(nexus.ResolveToBLuna(app, snapshot, bl))
-	util.SaveToFile(app, snapshot, "after-nexus")
-	check(lido.ExportBSTLunaHolders(app, snapshot, bl))
-	util.SaveToFile(app, snapshot, "lido")
-	check(lido.ExportLidoRewards(app, snapshot, bl))
-	util.SaveToFile(app, snapshot, "after-lido-rewards")
-	check(lido.ResolveLidoLuna(app, snapshot, bl))
-	util.SaveToFile(app, snapshot, "after-lido")
-	check(prism.ResolveToLuna(app, snapshot, bl))
-	util.SaveToFile(app, snapshot, "after-prism")
-	check(steak.ResolveSteakLuna(app, snapshot))
-	util.SaveToFile(app, snapshot, "after-steak")
-	check(stader.ResolveToLuna(app, snapshot))
-	util.SaveToFile(app, snapshot, "after-stader")
+	bl.Register(profile.ExtraBlacklist)
+	return bl
+}
 
-	// Collapse all balances
-	snapshot = util.MergeSnapshots(make(util.SnapshotBalanceAggregateMap), snapshot)
-	finalSnapshot := checkWithSs(generic.HandleContractBalances(app, snapshot, contractMap, bl))
+type stepResult struct {
+	name string
+	out  util.ExportOutput
+	err  error
+}
 
-	if snapshotType == util.Snapshot(util.PostAttack) {
-		for _, sbs := range finalSnapshot {
-			for i, b := range sbs {
-				if b.Denom == util.DenomAUST {
-					sbs[i] = util.SnapshotBalance{
-						Denom:   util.DenomUST,
-						Balance: b.Balance,
-					}
-				}
+// isExclusive reports whether a stage must run alone. Merge, resolver and
+// finalize stages all rewrite the shared merged snapshot.
+func isExclusive(e util.Exporter) bool {
+	return !e.Kind().IsProtocol()
+}
+
+// runPlan runs and audits every step of the plan, recording outputs in state.
+// Up to workers protocol exporters run at once as soon as their inputs are ready;
+// exclusive stages run alone, in plan order. Protocol exporters go through cache
+// unless it is nil.
+func runPlan(app *terra.TerraApp, bl util.Blacklist, state *util.ExportState, plan *util.Plan, cache *util.Cache, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	done := make(map[string]bool)
+	started := make(map[string]bool)
+	results := make(chan stepResult)
+	running := 0
+	exclusiveRunning := false
+
+	run := func(e util.Exporter, deps map[string]util.ExportOutput) {
+		var out util.ExportOutput
+		var err error
+		if cache != nil && !isExclusive(e) {
+			var hit bool
+			out, hit, err = cache.Run(app, e, bl, state, deps)
+			if hit {
+				app.Logger().Info(fmt.Sprintf("%s: using cached output", e.Name()))
 			}
+		} else {
+			out, err = util.Export(app, e, bl, state)
 		}
+		if err != nil {
+			results <- stepResult{name: e.Name(), err: fmt.Errorf("%s: %v", e.Name(), err)}
+			return
+		}
+		// failed invariants are collected in the audit report instead of failing the step
+		audit := state.Audit.For(e.Name())
+		if err := e.Audit(app, out, audit); err != nil {
+			audit.Fail(err)
+		}
+		results <- stepResult{name: e.Name(), out: out}
 	}
-	util.SaveToFile(app, finalSnapshot, "before-remove-contracts")
-
-	// remove all contract holdings from snapshot, minus some whitelisted ones
-	util.RemoveContractBalances(finalSnapshot, contractMap)
 
-	finalAudit(app, finalSnapshot, snapshotType)
-
-	return finalSnapshot.ExportToBalances()
-}
+	var firstErr error
+	for len(done) < len(plan.Steps) {
+		if firstErr == nil && !exclusiveRunning {
+		schedule:
+			for _, step := range plan.Steps {
+				e := step.Exporter
+				if started[e.Name()] {
+					continue
+				}
+				for _, dep := range step.DependsOn {
+					if !done[dep] {
+						continue schedule
+					}
+				}
+				if isExclusive(e) {
+					if running > 0 {
+						break
+					}
+					exclusiveRunning = true
+				} else if running >= workers {
+					break
+				}
+				deps := make(map[string]util.ExportOutput)
+				for _, dep := range step.DependsOn {
+					deps[dep] = state.Output(dep)
+				}
+				started[e.Name()] = true
+				running++
+				go run(e, deps)
+				if exclusiveRunning {
+					break
+				}
+			}
+		}
+		if running == 0 {
+			if firstErr != nil {
+				return firstErr
+			}
+			return fmt.Errorf("export plan stalled after %d of %d stages", len(done), len(plan.Steps))
+		}
 
-func NewBlacklist() util.Blacklist {
-	return util.Blacklist{
-		util.DenomUST:  []string{},
-		util.DenomLUNA: []string{},
-		util.DenomAUST: []string{},
+		res := <-results
+		running--
+		exclusiveRunning = false
+		if res.err != nil {
+			// let running exporters finish before failing
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+		// balances nobody attributed come from the stage that produced them
+		if res.out.Snapshot != nil {
+			res.out.Snapshot.Attribute(res.name)
+		} else {
+			state.Snapshot.Attribute(res.name)
+		}
+		state.SetOutput(res.name, res.out)
+		done[res.name] = true
 	}
+	return firstErr
 }
 
-func exportCompounders(app *terra.TerraApp, snaphotType util.Snapshot, snapshot util.SnapshotBalanceAggregateMap) (map[string]map[string]map[string]sdk.Int, error) {
-	finalMap := make(map[string]map[string]map[string]sdk.Int)
-	specLps, err := util.CachedMap3(spectrum.ExportSpecVaultLPs, "spectrum", app, snapshot)
-	if err != nil {
-		return nil, err
+// mergeProtocols merges every protocol snapshot, reports funds counted by
+// several exporters and applies the blacklist.
+func mergeProtocols(app *terra.TerraApp, bl util.Blacklist, state *util.ExportState) (util.ExportOutput, error) {
+	var snapshots []util.SnapshotBalanceAggregateMap
+	for _, name := range state.OutputNames() {
+		if out := state.Output(name); out.Snapshot != nil {
+			snapshots = append(snapshots, out.Snapshot)
+		}
 	}
-	for k, v := range specLps {
-		finalMap[k] = v
+	state.Snapshot = util.MergeSnapshots(snapshots...)
+	overlaps := DetectOverlaps(state, state.Snapshot, bl)
+	if err := auditOverlaps(overlaps, state.Audit.For("merge"), state.Folder); err != nil {
+		return util.ExportOutput{}, err
 	}
-	apolloLps, err := util.CachedMap3(apollo.ExportApolloVaultLPs, "apollo", app, snapshot)
+	state.Snapshot.ApplyBlackList(bl)
+
+	return util.ExportOutput{}, util.SaveToFile(app, state.Snapshot, "after-protocols")
+}
+
+// finalizeSnapshot collapses the resolved snapshot, splits contract balances
+// and removes the remaining contract holdings.
+func finalizeSnapshot(app *terra.TerraApp, bl util.Blacklist, state *util.ExportState, profile Profile) (util.ExportOutput, error) {
+	contractMap := util.SmartContractsAddresses
+
+	// Collapse all balances
+	snapshot := util.MergeSnapshots(make(util.SnapshotBalanceAggregateMap), state.Snapshot)
+	finalSnapshot, err := generic.HandleContractBalances(app, snapshot, contractMap, bl)
 	if err != nil {
-		return nil, err
+		return util.ExportOutput{}, err
 	}
-	for k, v := range apolloLps {
-		finalMap[k] = v
-	}
-	if snaphotType == util.Snapshot(util.PreAttack) {
-		marsLps, err := util.CachedMap3(mars.ExportFieldOfMarsLpTokens, "mars-field", app, snapshot)
-		if err != nil {
-			return nil, err
-		}
-		for k, v := range marsLps {
-			finalMap[k] = v
+
+	if profile.AUSTToUST == AUSTConvert {
+		for _, sbs := range finalSnapshot {
+			for i, b := range sbs {
+				if b.Denom == util.DenomAUST {
+					sbs[i] = b.Convert(util.DenomUST, sdk.OneDec(), util.AddressAUST)
+				}
+			}
 		}
 	}
-	mirrorLps, err := util.CachedMap3(mirror.ExportMirrorLpStakers, "mirror", app, snapshot)
-	if err != nil {
-		return nil, err
+	if err := util.SaveToFile(app, finalSnapshot, "before-remove-contracts"); err != nil {
+		return util.ExportOutput{}, err
 	}
-	for k, v := range mirrorLps {
-		finalMap[k] = v
+	if err := writeUnattributed(app, state.Folder, finalSnapshot, contractMap, profile); err != nil {
+		return util.ExportOutput{}, err
 	}
-	marsLps, err := util.CachedMap3(mars.ExportMarsAuctionLpHolders, "mars-auction", app, snapshot)
-	if err != nil {
-		return nil, err
+
+	// remove all contract holdings from snapshot, minus some whitelisted ones
+	util.RemoveContractBalances(finalSnapshot, contractMap, profile.WhitelistedContracts...)
+
+	for addr, sbs := range finalSnapshot {
+		var kept []util.SnapshotBalance
+		for _, sb := range sbs {
+			if profile.keepsDenom(sb.Denom) {
+				kept = append(kept, sb)
+			}
+		}
+		finalSnapshot[addr] = kept
 	}
-	for k, v := range marsLps {
-		finalMap[k] = v
+
+	if err := finalAudit(app, finalSnapshot, profile, state.Audit.For("finalize")); err != nil {
+		return util.ExportOutput{}, err
 	}
-	astroportLps, err := util.CachedMap3(astroport.ExportAstroportLockdrop, "astro-lockdrop", app, snapshot)
+
+	state.Snapshot = finalSnapshot
+	return util.ExportOutput{}, util.SaveToFile(app, finalSnapshot, "final")
+}
+
+// writeUnattributed saves the contract balances about to be removed from
+// snapshot as unattributed-contracts.csv in folder.
+func writeUnattributed(app *terra.TerraApp, folder string, snapshot util.SnapshotBalanceAggregateMap, contractMap common.ContractsMap, profile Profile) error {
+	prices, err := contractPrices(app)
 	if err != nil {
-		return nil, err
-	}
-	for k, v := range astroportLps {
-		finalMap[k] = v
+		return err
 	}
-	kineticLps, err := util.CachedMap3(kinetic.ExportKineticLpHoldings, "kinetic-lockdrop-lps", app, snapshot)
+	f, err := os.Create(filepath.Join(folder, "unattributed-contracts.csv"))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	for k, v := range kineticLps {
-		finalMap[k] = v
+	defer f.Close()
+	if err := WriteUnattributedCSV(f, UnattributedBalances(snapshot, contractMap, profile, prices)); err != nil {
+		return err
 	}
-
-	return finalMap, nil
+	return f.Close()
 }
 
-func checkWithSs(snapshot util.SnapshotBalanceAggregateMap, err error) util.SnapshotBalanceAggregateMap {
+func check(err error) {
 	if err != nil {
 		panic(err)
 	}
-	return snapshot
 }
 
-func check(err error) {
-	if err != nil {
-		panic(err)
+// zeroSupplyDenoms are the denoms the final snapshot must not hold: every
+// resolved asset, and the aUST address to catch it used as a denom.
+func zeroSupplyDenoms() []string {
+	denoms := []string{util.AUST}
+	for _, a := range util.Assets() {
+		if a.Resolved() {
+			denoms = append(denoms, a.Denom)
+		}
 	}
+	return denoms
 }
 
-func finalAudit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, snapshotType util.Snapshot) error {
+// finalAudit checks that every derivative was resolved and compares the
+// supplies of the remaining denoms to the snapshot.
+func finalAudit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, profile Profile, audit *util.Audit) error {
 	app.Logger().Info("Final audit")
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
 
 	// assert no other staking derivatives exist in the snapshot
-	util.AssertZeroSupply(snapshot, util.AUST) // prevent accidental address as denom
-	util.AssertZeroSupply(snapshot, util.DenomBLUNA)
-	util.AssertZeroSupply(snapshot, util.DenomSTLUNA)
-	util.AssertZeroSupply(snapshot, util.DenomSTEAK)
-	util.AssertZeroSupply(snapshot, util.DenomNLUNA)
-	util.AssertZeroSupply(snapshot, util.DenomCLUNA)
-	util.AssertZeroSupply(snapshot, util.DenomPLUNA)
-	util.AssertZeroSupply(snapshot, util.DenomLUNAX)
-
-	if snapshotType == util.Snapshot(util.PreAttack) {
-		// expect to have aUST in the snapshot
-		aUstHoldings := snapshot.FilterByDenom(util.DenomAUST)
-		err := util.AssertCw20Supply(ctx, q, util.AUST, aUstHoldings)
-		if err != nil {
-			app.Logger().Info(err.Error())
-		}
+	for _, denom := range zeroSupplyDenoms() {
+		audit.Check(util.NewInvariant(fmt.Sprintf("no %s left", denom), util.SeverityError, 1), sdk.ZeroInt(), util.Sum(snapshot.FilterByDenom(denom)))
+	}
 
-		// expect to have LUNA in the snapshot
-		lunaHoldings := snapshot.FilterByDenom(util.DenomLUNA)
-		err = util.AssertNativeSupply(ctx, app.BankKeeper, util.DenomLUNA, lunaHoldings)
+	if profile.AUSTToUST == AUSTKeep {
+		// expect to have aUST in the snapshot
+		aUstSupply, err := util.GetCW20TotalSupply(ctx, q, util.AUST)
 		if err != nil {
-			app.Logger().Info(err.Error())
+			return err
 		}
+		audit.Check(util.NewInvariant("aUST supply", util.SeverityWarn, 2000000), aUstSupply, util.Sum(snapshot.FilterByDenom(util.DenomAUST)))
 	} else {
 		// expect to have UST in the snapshot
-		ustHoldings := snapshot.FilterByDenom(util.DenomUST)
-		err := util.AssertNativeSupply(ctx, app.BankKeeper, util.DenomUST, ustHoldings)
+		ustSupply, err := util.GetNativeSupply(ctx, app.BankKeeper, util.DenomUST)
 		if err != nil {
-			app.Logger().Info(err.Error())
+			return err
 		}
+		audit.Check(util.NewInvariant("uusd supply", util.SeverityWarn, 2000000), ustSupply, util.Sum(snapshot.FilterByDenom(util.DenomUST)))
+	}
+
+	// expect to have LUNA in the snapshot
+	lunaSupply, err := util.GetNativeSupply(ctx, app.BankKeeper, util.DenomLUNA)
+	if err != nil {
+		return err
+	}
+	audit.Check(util.NewInvariant("uluna supply", util.SeverityWarn, 2000000), lunaSupply, util.Sum(snapshot.FilterByDenom(util.DenomLUNA)))
 
-		// expect to have LUNA in the snapshot
-		lunaHoldings := snapshot.FilterByDenom(util.DenomLUNA)
-		err = util.AssertNativeSupply(ctx, app.BankKeeper, util.DenomLUNA, lunaHoldings)
+	// expect to have every tracked token in the snapshot
+	for _, token := range util.TrackedTokens() {
+		supply, err := util.GetCW20TotalSupply(ctx, q, token)
 		if err != nil {
-			app.Logger().Info(err.Error())
+			return err
 		}
+		// holdings of contracts that are not looked through were removed, and
+		// listed in unattributed-contracts.csv
+		holders := snapshot.FilterByDenom(token)
+		inv := util.Invariant{Name: fmt.Sprintf("%s supply", token), Severity: util.SeverityWarn, Tolerance: roundingTolerance(len(holders))}
+		audit.Check(inv, supply, util.Sum(holders))
 	}
 	return nil
 }
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"sync"
+	"testing"
+
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/util"
+)
+
+func TestRunPlanRunsExclusiveStagesAlone(t *testing.T) {
+	var mtx sync.Mutex
+	active := 0
+	var order []string
+
+	stage := func(name string, kind util.ExporterKind) *util.FuncExporter {
+		return util.NewExporter(name, kind, func(*terra.TerraApp, util.Blacklist, *util.ExportState) (util.ExportOutput, error) {
+			mtx.Lock()
+			active++
+			if kind == util.KindMerge && active != 1 {
+				t.Errorf("%s ran alongside another stage", name)
+			}
+			order = append(order, name)
+			mtx.Unlock()
+
+			mtx.Lock()
+			active--
+			mtx.Unlock()
+			return util.ExportOutput{}, nil
+		})
+	}
+
+	stages := []util.Exporter{
+		stage("a", util.KindSBA).Produces("a-out"),
+		stage("b", util.KindSBA).Produces("b-out"),
+		stage("c", util.KindSBA).Consumes("a-out").Produces("c-out"),
+		stage("r1", util.KindMerge).Consumes("a-out", "b-out", "c-out"),
+		stage("r2", util.KindMerge).Consumes("a-out", "b-out", "c-out"),
+	}
+	plan, err := util.BuildPlan(util.Snapshot(util.PostAttack), stages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := util.NewExportState(util.Snapshot(util.PostAttack))
+	if err := runPlan(nil, util.Blacklist{}, state, plan, nil, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(order) != 5 || order[3] != "r1" || order[4] != "r2" {
+		t.Fatalf("unexpected order %v", order)
+	}
+	if len(state.OutputNames()) != 5 {
+		t.Fatalf("expected 5 outputs, got %v", state.OutputNames())
+	}
+}
+
+func TestAssetResolversAreRegistered(t *testing.T) {
+	for _, a := range util.Assets() {
+		if !a.Resolved() {
+			continue
+		}
+		e, ok := util.GetExporter(a.Resolver)
+		if !ok {
+			t.Fatalf("resolver %s of %s is not registered", a.Resolver, a.Denom)
+		}
+		produced := false
+		for _, r := range e.Outputs() {
+			produced = produced || r == util.DenomConversion(a.Denom, a.Underlying)
+		}
+		if !produced {
+			t.Fatalf("%s does not convert %s to %s", a.Resolver, a.Denom, a.Underlying)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/terra-money/core/app/export/util"
+)
+
+const (
+	DiffAdded     = "added"
+	DiffRemoved   = "removed"
+	DiffIncreased = "increased"
+	DiffDecreased = "decreased"
+)
+
+// AddressDiff is the change of one denom held by one address.
+type AddressDiff struct {
+	Address string  `json:"address"`
+	Denom   string  `json:"denom"`
+	Kind    string  `json:"kind"`
+	Before  sdk.Int `json:"before"`
+	After   sdk.Int `json:"after"`
+	Delta   sdk.Int `json:"delta"`
+}
+
+// DenomDiff counts and totals the changes of one denom.
+type DenomDiff struct {
+	Denom           string  `json:"denom"`
+	Before          sdk.Int `json:"before"`
+	After           sdk.Int `json:"after"`
+	Added           int     `json:"added"`
+	AddedAmount     sdk.Int `json:"added_amount"`
+	Removed         int     `json:"removed"`
+	RemovedAmount   sdk.Int `json:"removed_amount"`
+	Increased       int     `json:"increased"`
+	IncreasedAmount sdk.Int `json:"increased_amount"`
+	Decreased       int     `json:"decreased"`
+	DecreasedAmount sdk.Int `json:"decreased_amount"`
+}
+
+// ExporterDiff is the change of the amount of a denom attributed to an
+// exporter, from the balance sources recorded in provenance mode.
+type ExporterDiff struct {
+	Exporter string  `json:"exporter"`
+	Denom    string  `json:"denom"`
+	Before   sdk.Int `json:"before"`
+	After    sdk.Int `json:"after"`
+	Delta    sdk.Int `json:"delta"`
+}
+
+// SnapshotDiff is the report of DiffSnapshots.
+type SnapshotDiff struct {
+	Threshold sdk.Int        `json:"threshold"`
+	Denoms    []DenomDiff    `json:"denoms"`
+	Exporters []ExporterDiff `json:"exporters,omitempty"`
+	Changes   []AddressDiff  `json:"changes"`
+}
+
+// DiffSnapshots compares the balances of a and b. Changes whose absolute delta
+// is not above threshold are left out of the report. Totals are grouped by
+// exporter if either snapshot carries balance sources.
+func DiffSnapshots(a, b util.SnapshotBalanceAggregateMap, threshold sdk.Int) (*SnapshotDiff, error) {
+	before, err := coinsByAddress(a)
+	if err != nil {
+		return nil, err
+	}
+	after, err := coinsByAddress(b)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{Threshold: threshold}
+	denoms := make(map[string]*DenomDiff)
+	denomDiff := func(denom string) *DenomDiff {
+		if d, ok := denoms[denom]; ok {
+			return d
+		}
+		d := &DenomDiff{
+			Denom:           denom,
+			Before:          sdk.ZeroInt(),
+			After:           sdk.ZeroInt(),
+			AddedAmount:     sdk.ZeroInt(),
+			RemovedAmount:   sdk.ZeroInt(),
+			IncreasedAmount: sdk.ZeroInt(),
+			DecreasedAmount: sdk.ZeroInt(),
+		}
+		denoms[denom] = d
+		return d
+	}
+
+	for addr, coins := range before {
+		for denom, amount := range coins {
+			d := denomDiff(denom)
+			d.Before = d.Before.Add(amount)
+		}
+		if _, ok := after[addr]; !ok {
+			after[addr] = nil
+		}
+	}
+	for addr, coins := range after {
+		for _, denom := range denomsOf(before[addr], coins) {
+			prev, next := orZero(before[addr][denom]), orZero(coins[denom])
+			d := denomDiff(denom)
+			d.After = d.After.Add(next)
+
+			delta := next.Sub(prev)
+			if !delta.Abs().GT(threshold) {
+				continue
+			}
+			change := AddressDiff{Address: addr, Denom: denom, Before: prev, After: next, Delta: delta}
+			switch {
+			case prev.IsZero():
+				change.Kind = DiffAdded
+				d.Added++
+				d.AddedAmount = d.AddedAmount.Add(delta)
+			case next.IsZero():
+				change.Kind = DiffRemoved
+				d.Removed++
+				d.RemovedAmount = d.RemovedAmount.Sub(delta)
+			case delta.IsPositive():
+				change.Kind = DiffIncreased
+				d.Increased++
+				d.IncreasedAmount = d.IncreasedAmount.Add(delta)
+			default:
+				change.Kind = DiffDecreased
+				d.Decreased++
+				d.DecreasedAmount = d.DecreasedAmount.Sub(delta)
+			}
+			diff.Changes = append(diff.Changes, change)
+		}
+	}
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		ci, cj := diff.Changes[i], diff.Changes[j]
+		if ci.Denom != cj.Denom {
+			return ci.Denom < cj.Denom
+		}
+		return ci.Address < cj.Address
+	})
+	for _, d := range denoms {
+		diff.Denoms = append(diff.Denoms, *d)
+	}
+	sort.Slice(diff.Denoms, func(i, j int) bool { return diff.Denoms[i].Denom < diff.Denoms[j].Denom })
+
+	diff.Exporters = diffExporters(exporterTotals(a), exporterTotals(b))
+	return diff, nil
+}
+
+// coinsByAddress merges the balances of s by address and denom.
+func coinsByAddress(s util.SnapshotBalanceAggregateMap) (map[string]map[string]sdk.Int, error) {
+	m := make(map[string]map[string]sdk.Int, len(s))
+	for addr := range s {
+		coins, err := s.CoinsOf(addr)
+		if err != nil {
+			return nil, err
+		}
+		if len(coins) == 0 {
+			continue
+		}
+		m[addr] = make(map[string]sdk.Int, len(coins))
+		for _, c := range coins {
+			m[addr][c.Denom] = c.Amount
+		}
+	}
+	return m, nil
+}
+
+type exporterDenom struct {
+	exporter string
+	denom    string
+}
+
+// exporterTotals sums the recorded sources of s by exporter and by the denom
+// they ended up as.
+func exporterTotals(s util.SnapshotBalanceAggregateMap) map[exporterDenom]sdk.Int {
+	totals := make(map[exporterDenom]sdk.Int)
+	for _, sbs := range s {
+		for _, sb := range sbs {
+			for _, src := range sb.Sources {
+				k := exporterDenom{exporter: src.Exporter, denom: sb.Denom}
+				totals[k] = orZero(totals[k]).Add(src.Rate.MulInt(src.Amount).TruncateInt())
+			}
+		}
+	}
+	return totals
+}
+
+func diffExporters(before, after map[exporterDenom]sdk.Int) []ExporterDiff {
+	keys := make(map[exporterDenom]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	var diffs []ExporterDiff
+	for k := range keys {
+		prev, next := orZero(before[k]), orZero(after[k])
+		diffs = append(diffs, ExporterDiff{Exporter: k.exporter, Denom: k.denom, Before: prev, After: next, Delta: next.Sub(prev)})
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Denom != diffs[j].Denom {
+			return diffs[i].Denom < diffs[j].Denom
+		}
+		return diffs[i].Exporter < diffs[j].Exporter
+	})
+	return diffs
+}
+
+// LoadSnapshot reads a snapshot from a genesis, a JSON list of bank balances,
+// a snapshot or cache entry saved in ./cache-<height>, or the csv and jsonl
+// snapshot outputs.
+func LoadSnapshot(path string) (util.SnapshotBalanceAggregateMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var s util.SnapshotBalanceAggregateMap
+	switch filepath.Ext(path) {
+	case ".csv":
+		s, err = readCSV(f)
+	case ".jsonl":
+		s, err = readJSONL(f)
+	default:
+		var data []byte
+		if data, err = io.ReadAll(f); err == nil {
+			s, err = readJSON(data)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %v", path, err)
+	}
+	return s, nil
+}
+
+func readJSON(data []byte) (util.SnapshotBalanceAggregateMap, error) {
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		var balances []banktypes.Balance
+		if err := json.Unmarshal(data, &balances); err != nil {
+			return nil, err
+		}
+		return fromBalances(balances), nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["app_state"]; ok {
+		var genesis struct {
+			AppState struct {
+				Bank struct {
+					Balances []banktypes.Balance `json:"balances"`
+				} `json:"bank"`
+			} `json:"app_state"`
+		}
+		if err := json.Unmarshal(data, &genesis); err != nil {
+			return nil, err
+		}
+		return fromBalances(genesis.AppState.Bank.Balances), nil
+	}
+	if _, ok := fields["snapshot"]; ok {
+		var out util.ExportOutput
+		if err := json.Unmarshal(data, &out); err != nil {
+			return nil, err
+		}
+		return out.Snapshot, nil
+	}
+	var s util.SnapshotBalanceAggregateMap
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func readCSV(r io.Reader) (util.SnapshotBalanceAggregateMap, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = 3
+	s := make(util.SnapshotBalanceAggregateMap)
+	for line := 0; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return s, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if line == 0 && record[0] == "address" {
+			continue
+		}
+		amount, ok := sdk.NewIntFromString(record[2])
+		if !ok {
+			return nil, fmt.Errorf("invalid amount %q on line %d", record[2], line+1)
+		}
+		s.AppendOrAddBalance(record[0], util.SnapshotBalance{Denom: record[1], Balance: amount})
+	}
+}
+
+func readJSONL(r io.Reader) (util.SnapshotBalanceAggregateMap, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var balances []banktypes.Balance
+	for {
+		var b banktypes.Balance
+		if err := dec.Decode(&b); err == io.EOF {
+			return fromBalances(balances), nil
+		} else if err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+}
+
+func fromBalances(balances []banktypes.Balance) util.SnapshotBalanceAggregateMap {
+	s := make(util.SnapshotBalanceAggregateMap, len(balances))
+	for _, b := range balances {
+		for _, c := range b.Coins {
+			s.AppendOrAddBalance(b.Address, util.SnapshotBalance{Denom: c.Denom, Balance: c.Amount})
+		}
+	}
+	return s
+}
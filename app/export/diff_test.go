@@ -0,0 +1,96 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/terra-money/core/app/export/util"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	before := util.SnapshotBalanceAggregateMap{
+		"kept":      {{Denom: util.DenomLUNA, Balance: sdk.NewInt(100)}},
+		"removed":   {{Denom: util.DenomLUNA, Balance: sdk.NewInt(50)}},
+		"increased": {{Denom: util.DenomUST, Balance: sdk.NewInt(10)}},
+		"decreased": {{Denom: util.DenomUST, Balance: sdk.NewInt(10)}},
+		"small":     {{Denom: util.DenomUST, Balance: sdk.NewInt(10)}},
+	}
+	after := util.SnapshotBalanceAggregateMap{
+		"kept":      {{Denom: util.DenomLUNA, Balance: sdk.NewInt(100)}},
+		"added":     {{Denom: util.DenomLUNA, Balance: sdk.NewInt(30)}},
+		"increased": {{Denom: util.DenomUST, Balance: sdk.NewInt(20)}},
+		"decreased": {{Denom: util.DenomUST, Balance: sdk.NewInt(3)}},
+		"small":     {{Denom: util.DenomUST, Balance: sdk.NewInt(11)}},
+	}
+
+	diff, err := DiffSnapshots(before, after, sdk.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]string)
+	for _, c := range diff.Changes {
+		kinds[c.Address] = c.Kind
+	}
+	expected := map[string]string{"added": DiffAdded, "removed": DiffRemoved, "increased": DiffIncreased, "decreased": DiffDecreased}
+	if len(kinds) != len(expected) {
+		t.Fatalf("unexpected changes %v", kinds)
+	}
+	for addr, kind := range expected {
+		if kinds[addr] != kind {
+			t.Fatalf("expected %s to be %s, got %q", addr, kind, kinds[addr])
+		}
+	}
+
+	if len(diff.Denoms) != 2 {
+		t.Fatalf("expected two denoms, got %v", diff.Denoms)
+	}
+	for _, d := range diff.Denoms {
+		switch d.Denom {
+		case util.DenomLUNA:
+			if !d.AddedAmount.Equal(sdk.NewInt(30)) || !d.RemovedAmount.Equal(sdk.NewInt(50)) || !d.After.Equal(sdk.NewInt(130)) {
+				t.Fatalf("unexpected luna totals %+v", d)
+			}
+		case util.DenomUST:
+			if !d.IncreasedAmount.Equal(sdk.NewInt(10)) || !d.DecreasedAmount.Equal(sdk.NewInt(7)) || d.Increased != 1 {
+				t.Fatalf("unexpected ust totals %+v", d)
+			}
+		}
+	}
+}
+
+func TestLoadSnapshotFormats(t *testing.T) {
+	s := util.SnapshotBalanceAggregateMap{
+		"addr1": {{Denom: util.DenomLUNA, Balance: sdk.NewInt(7)}},
+		"addr2": {{Denom: util.DenomUST, Balance: sdk.NewInt(3)}, {Denom: util.DenomLUNA, Balance: sdk.NewInt(1)}},
+	}
+	dir := t.TempDir()
+	writers := map[string]func(f *os.File) error{
+		"balances.csv":   func(f *os.File) error { return util.WriteCSV(f, s) },
+		"balances.jsonl": func(f *os.File) error { return util.WriteJSONL(f, s) },
+	}
+	for name, write := range writers {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := write(f); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		loaded, err := LoadSnapshot(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff, err := DiffSnapshots(s, loaded, sdk.ZeroInt())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diff.Changes) != 0 {
+			t.Fatalf("%s did not round trip: %v", name, diff.Changes)
+		}
+	}
+}
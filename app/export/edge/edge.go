@@ -87,7 +87,7 @@ func ExportContract(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanc
 	return snapshot, nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
 	for _, token := range EdgeProtocolTokens {
@@ -105,10 +105,7 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 			}
 		}
 		denom := util.MapContractToDenom(token)
-		err = util.AlmostEqual(fmt.Sprintf("edge: %s", denom), snapshot.SumOfDenom(denom), balance, sdk.NewInt(100000))
-		if err != nil {
-			return err
-		}
+		audit.Check(util.NewInvariant(fmt.Sprintf("%s in pool", denom), util.SeverityError, 100000), balance, snapshot.SumOfDenom(denom))
 	}
 	return nil
 }
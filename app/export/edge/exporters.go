@@ -0,0 +1,9 @@
+package edge
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("edge", ExportContract, Audit).WithVersion(2))
+}
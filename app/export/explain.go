@@ -0,0 +1,267 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/terra-money/core/app/export/generic/cw3"
+	"github.com/terra-money/core/app/export/util"
+)
+
+// ExplainStep is an address's balances after one pipeline stage, with notes on
+// what the stage did to them.
+type ExplainStep struct {
+	Stage    string
+	Kind     util.ExporterKind
+	Balances map[string]sdk.Int
+	Notes    []string
+}
+
+// Explanation traces one address through the snapshots saved by an export.
+type Explanation struct {
+	Address string
+	// Contributions lists the protocol exporters that found balances for the address
+	Contributions []ExplainStep
+	// Stages follows the merged balances through merge, resolvers and finalize
+	Stages []ExplainStep
+	// Final holds the balances in the final snapshot, with their sources if recorded
+	Final []util.SnapshotBalance
+}
+
+// ExplainAddress rebuilds how addr got its final balances from the cache
+// entries and the snapshots saved by SaveToFile by an export run with profile.
+// Cache entries that do not match the profile are not used.
+func ExplainAddress(profile Profile, provenance bool, addr string) (*Explanation, error) {
+	height := profile.Height
+	if err := util.TrackTokens(profile.Tokens...); err != nil {
+		return nil, err
+	}
+	plan, err := BuildExportPlan(profile, ProtocolFilter{})
+	if err != nil {
+		return nil, err
+	}
+	cache, err := OpenCache(profile, provenance, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]util.CacheStatus)
+	for _, status := range cache.Verify(plan) {
+		statuses[status.Exporter] = status
+	}
+
+	ex := &Explanation{Address: addr}
+	raw := make(map[string]sdk.Int)
+	// exporters that blacklisted addr, by denom
+	blacklistedBy := make(map[string][]string)
+	for denom, addrs := range NewBlacklist(profile) {
+		for _, a := range addrs {
+			if a == addr {
+				blacklistedBy[denom] = append(blacklistedBy[denom], "export defaults")
+			}
+		}
+	}
+
+	if vesting, err := util.LoadFromFile(height, "vesting"); err == nil {
+		if balances := balancesOf(vesting, addr); len(balances) > 0 {
+			ex.Contributions = append(ex.Contributions, ExplainStep{Stage: "vesting", Balances: balances})
+			addBalances(raw, balances)
+		}
+	}
+
+	var uncached, stale []string
+	for _, step := range plan.Steps {
+		e := step.Exporter
+		if !e.Kind().IsProtocol() {
+			continue
+		}
+		status := statuses[e.Name()]
+		switch status.State {
+		case util.CacheOK:
+		case util.CacheMissing:
+			uncached = append(uncached, e.Name())
+			continue
+		default:
+			stale = append(stale, fmt.Sprintf("%s (%s)", e.Name(), status.State))
+			continue
+		}
+		out, err := cache.LoadEntry(status.Entry)
+		if err != nil {
+			return nil, err
+		}
+
+		contribution := ExplainStep{Stage: e.Name(), Kind: e.Kind(), Balances: balancesOf(out.Snapshot, addr)}
+		for denom, addrs := range out.BlacklistDelta {
+			for _, a := range addrs {
+				if a == addr {
+					blacklistedBy[denom] = append(blacklistedBy[denom], e.Name())
+					contribution.Notes = append(contribution.Notes, fmt.Sprintf("blacklisted for %s", denom))
+				}
+			}
+		}
+		for vault, lps := range out.LpHoldings {
+			for lp, holders := range lps {
+				if amount, ok := holders[addr]; ok {
+					contribution.Notes = append(contribution.Notes, fmt.Sprintf("holds %s of lp %s in vault %s", amount, lp, vault))
+				}
+			}
+		}
+		if len(contribution.Balances) > 0 || len(contribution.Notes) > 0 {
+			ex.Contributions = append(ex.Contributions, contribution)
+			addBalances(raw, contribution.Balances)
+		}
+	}
+
+	if len(uncached) > 0 {
+		ex.Contributions = append(ex.Contributions, ExplainStep{
+			Stage: "uncached",
+			Notes: []string{fmt.Sprintf("no cache entry, contributions unknown: %s", strings.Join(uncached, ", "))},
+		})
+	}
+	if len(stale) > 0 {
+		ex.Contributions = append(ex.Contributions, ExplainStep{
+			Stage: "stale",
+			Notes: []string{fmt.Sprintf("cache entry not from this profile, contributions unknown: %s", strings.Join(stale, ", "))},
+		})
+	}
+
+	known, err := cw3.KnownContracts()
+	if err != nil {
+		return nil, err
+	}
+
+	prev := raw
+	for _, step := range plan.Steps {
+		e := step.Exporter
+		switch e.Kind() {
+		case util.KindMerge:
+			next, ok, err := loadBalances(height, "after-protocols", addr)
+			if err != nil {
+				return nil, err
+			}
+			ex.Stages = append(ex.Stages, explainStep(e, prev, next, ok, blacklistedBy))
+			if ok {
+				prev = next
+			}
+		case util.KindResolver:
+			next, ok, err := loadBalances(height, fmt.Sprintf("after-%s", e.Name()), addr)
+			if err != nil {
+				return nil, err
+			}
+			ex.Stages = append(ex.Stages, explainStep(e, prev, next, ok, blacklistedBy))
+			if ok {
+				prev = next
+			}
+		case util.KindFinalize:
+			next, ok, err := loadBalances(height, "before-remove-contracts", addr)
+			if err != nil {
+				return nil, err
+			}
+			s := explainStep(e, prev, next, ok, blacklistedBy)
+			for _, k := range known {
+				if k.Contract == addr {
+					s.Notes = append(s.Notes, fmt.Sprintf("mapKnownContracts reassigned all balances to %s", k.Owner))
+				}
+				if k.Owner == addr {
+					s.Notes = append(s.Notes, fmt.Sprintf("mapKnownContracts assigned the balances of %s", k.Contract))
+				}
+			}
+			ex.Stages = append(ex.Stages, s)
+
+			final, err := util.LoadFromFile(height, "final")
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			ex.Final = final[addr]
+			removed := ExplainStep{Stage: "remove-contracts", Kind: util.KindFinalize, Balances: balancesOf(final, addr)}
+			if _, ok := final[addr]; !ok && len(next) > 0 {
+				removed.Notes = append(removed.Notes, "dropped by RemoveContractBalances as a contract holding")
+			}
+			ex.Stages = append(ex.Stages, removed)
+		}
+	}
+	return ex, nil
+}
+
+// explainStep describes the change from prev to next made by stage e.
+func explainStep(e util.Exporter, prev, next map[string]sdk.Int, saved bool, blacklistedBy map[string][]string) ExplainStep {
+	if !saved {
+		return ExplainStep{Stage: e.Name(), Kind: e.Kind(), Balances: prev, Notes: []string{"no saved snapshot for this stage"}}
+	}
+	step := ExplainStep{Stage: e.Name(), Kind: e.Kind(), Balances: next}
+
+	var decreased, increased []string
+	for _, denom := range denomsOf(prev, next) {
+		before, after := orZero(prev[denom]), orZero(next[denom])
+		switch {
+		case after.IsZero() && !before.IsZero() && len(blacklistedBy[denom]) > 0:
+			step.Notes = append(step.Notes, fmt.Sprintf("ApplyBlackList zeroed %s %s (blacklisted by %v)", before, denom, blacklistedBy[denom]))
+		case after.LT(before):
+			decreased = append(decreased, fmt.Sprintf("-%s %s", before.Sub(after), denom))
+		case after.GT(before):
+			increased = append(increased, fmt.Sprintf("+%s %s", after.Sub(before), denom))
+		}
+	}
+	switch {
+	case len(decreased) > 0 && len(increased) > 0:
+		step.Notes = append(step.Notes, fmt.Sprintf("converted %v into %v", decreased, increased))
+	case len(decreased) > 0:
+		step.Notes = append(step.Notes, fmt.Sprintf("removed %v", decreased))
+	case len(increased) > 0:
+		step.Notes = append(step.Notes, fmt.Sprintf("added %v", increased))
+	}
+	return step
+}
+
+func loadBalances(height int64, filename string, addr string) (map[string]sdk.Int, bool, error) {
+	snapshot, err := util.LoadFromFile(height, filename)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return balancesOf(snapshot, addr), true, nil
+}
+
+func balancesOf(snapshot util.SnapshotBalanceAggregateMap, addr string) map[string]sdk.Int {
+	balances := make(map[string]sdk.Int)
+	for _, sb := range snapshot[addr] {
+		if sb.Balance.IsNil() {
+			continue
+		}
+		balances[sb.Denom] = orZero(balances[sb.Denom]).Add(sb.Balance)
+	}
+	return balances
+}
+
+func addBalances(into map[string]sdk.Int, balances map[string]sdk.Int) {
+	for denom, amount := range balances {
+		into[denom] = orZero(into[denom]).Add(amount)
+	}
+}
+
+func denomsOf(ms ...map[string]sdk.Int) []string {
+	seen := make(map[string]bool)
+	var denoms []string
+	for _, m := range ms {
+		for denom := range m {
+			if !seen[denom] {
+				seen[denom] = true
+				denoms = append(denoms, denom)
+			}
+		}
+	}
+	sort.Strings(denoms)
+	return denoms
+}
+
+func orZero(i sdk.Int) sdk.Int {
+	if i.IsNil() {
+		return sdk.ZeroInt()
+	}
+	return i
+}
@@ -2,9 +2,9 @@ package cw3
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -26,29 +26,38 @@ type Voter struct {
 // For genesis snapshot, we split CW3 holdings for UST, aUST LUNA to all voters
 // We missed other staking derivatives, LP and lockdrop holdings
 // For the airdrop fix, we will index everything and remove what we have already airdropped
+// Fixed and flex multisigs and cw4 groups are split by their membership at the
+// snapshot height, unclassified contracts by the voters of their init message.
+// Contracts whose voters cannot be read keep their holdings, which end up in
+// the unattributed report.
 func ExportCW3(app *terra.TerraApp, contractsMap common.ContractsMap, snapshot util.SnapshotBalanceAggregateMap, bl util.Blacklist) error {
 	ctx := util.PrepCtx(app)
 	qs := util.PrepWasmQueryServer(app)
+	logger := app.Logger()
 
 	contractBalanceMap := make(map[string]map[string]sdk.Int)
-	for addr, ci := range contractsMap {
-		var initmsg Cw3InitMsg
-		if err := json.Unmarshal(ci.InitMsg, &initmsg); err != nil {
-			// not a cw3 contract
+	for _, addr := range multisigs(contractsMap) {
+		voters, err := CurrentVoters(ctx, qs, contractsMap[addr], util.ContractClasses.TypeOf(addr))
+		if err != nil {
+			logger.Error(fmt.Sprintf("cw3 %s: %v, its holdings are left unattributed", addr, err))
 			continue
 		}
 
-		if len(initmsg.Voters) == 0 {
+		// get total weight
+		var totalWeight int64
+		for _, voter := range voters {
+			totalWeight = totalWeight + int64(voter.Weight)
+		}
+		if totalWeight == 0 {
 			continue
 		}
+		tw := sdk.NewDec(totalWeight)
 
 		// register this contract in blacklist map
 		bl.RegisterAddress(util.DenomUST, addr)
 		bl.RegisterAddress(util.DenomLUNA, addr)
 		bl.RegisterAddress(util.DenomAUST, addr)
 
-		voters := initmsg.Voters
-
 		addrr, _ := sdk.AccAddressFromBech32(addr)
 		nativeBalance := app.BankKeeper.GetAllBalances(sdk.UnwrapSDKContext(ctx), addrr)
 		ustBalance := nativeBalance.AmountOf("uusd")
@@ -61,28 +70,21 @@ func ExportCW3(app *terra.TerraApp, contractsMap common.ContractsMap, snapshot u
 		contractBalanceMap[addr][util.DenomLUNA] = lunaBalance
 		contractBalanceMap[addr][util.DenomAUST] = aUSTBalance
 
-		// get total weight
-		var totalWeight int64
-		for _, voter := range voters {
-			totalWeight = totalWeight + int64(voter.Weight)
-		}
-		tw := sdk.NewDec(totalWeight)
-
 		// split funds, append to final balance
 		for _, voter := range voters {
 			w := sdk.NewDec(int64(voter.Weight))
 			snapshot.AppendOrAddBalance(voter.Address, util.SnapshotBalance{
 				Denom:   util.DenomUST,
 				Balance: sdk.NewDecFromInt(ustBalance).Mul(w).Quo(tw).TruncateInt(),
-			})
+			}.WithSource(cw3Source(addr, util.DenomUST, ustBalance, w.Quo(tw))))
 			snapshot.AppendOrAddBalance(voter.Address, util.SnapshotBalance{
 				Denom:   util.DenomLUNA,
 				Balance: sdk.NewDecFromInt(lunaBalance).Mul(w).Quo(tw).TruncateInt(),
-			})
+			}.WithSource(cw3Source(addr, util.DenomLUNA, lunaBalance, w.Quo(tw))))
 			snapshot.AppendOrAddBalance(voter.Address, util.SnapshotBalance{
 				Denom:   util.DenomAUST,
 				Balance: sdk.NewDecFromInt(aUSTBalance).Mul(w).Quo(tw).TruncateInt(),
-			})
+			}.WithSource(cw3Source(addr, util.DenomAUST, aUSTBalance, w.Quo(tw))))
 		}
 	}
 
@@ -99,7 +101,8 @@ func ExportCW3(app *terra.TerraApp, contractsMap common.ContractsMap, snapshot u
 				snapshot[addr][i] = util.SnapshotBalance{
 					Denom:   sbs.Denom,
 					Balance: remaining,
-				}
+					Sources: sbs.Sources,
+				}.WithSource(cw3Source(addr, sbs.Denom, balances[sbs.Denom].Neg(), sdk.OneDec()))
 			}
 		}
 	}
@@ -107,17 +110,50 @@ func ExportCW3(app *terra.TerraApp, contractsMap common.ContractsMap, snapshot u
 	return nil
 }
 
+// multisigs returns the classified multisigs and groups, then the unclassified
+// contracts whose init message lists voters, as fixed multisigs that do not
+// answer the classifier probes are not classified.
+func multisigs(contractsMap common.ContractsMap) []string {
+	addrs := util.ContractClasses.ContractsOf(util.ContractCW3Fixed, util.ContractCW3Flex, util.ContractCW4Group)
+	var unclassified []string
+	for addr, ci := range contractsMap {
+		if util.ContractClasses.TypeOf(addr) == util.ContractUnknown && len(initVoters(ci)) != 0 {
+			unclassified = append(unclassified, addr)
+		}
+	}
+	sort.Strings(unclassified)
+	return append(addrs, unclassified...)
+}
+
+// cw3Source attributes a share of a cw3 contract's holdings to a voter.
+func cw3Source(contract string, denom string, amount sdk.Int, share sdk.Dec) util.Source {
+	return util.Source{
+		Exporter: "cw3",
+		Contract: contract,
+		Denom:    denom,
+		Amount:   amount,
+		Rate:     share,
+	}
+}
+
 const contractMappingFile = "./app/export/generic/common/contract-mapping.csv"
 
-func mapKnownContracts(snapshot util.SnapshotBalanceAggregateMap) {
+// KnownContract is a contract whose holdings are assigned to Owner.
+type KnownContract struct {
+	Contract string
+	Owner    string
+}
+
+// KnownContracts reads the contract to owner mapping, in file order.
+func KnownContracts() ([]KnownContract, error) {
 	file, err := os.Open(contractMappingFile)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
-	// For contracts that we know, assign it to the right owner
+	var known []KnownContract
 	var cAdd string
 	var rAdd string
 	for scanner.Scan() {
@@ -131,9 +167,22 @@ func mapKnownContracts(snapshot util.SnapshotBalanceAggregateMap) {
 		if err == nil {
 			rAdd = add.String()
 		}
-		for _, b := range snapshot[cAdd] {
-			snapshot.AppendOrAddBalance(rAdd, b)
+		known = append(known, KnownContract{Contract: cAdd, Owner: rAdd})
+	}
+	return known, scanner.Err()
+}
+
+func mapKnownContracts(snapshot util.SnapshotBalanceAggregateMap) {
+	known, err := KnownContracts()
+	if err != nil {
+		panic(err)
+	}
+
+	// For contracts that we know, assign it to the right owner
+	for _, k := range known {
+		for _, b := range snapshot[k.Contract] {
+			snapshot.AppendOrAddBalance(k.Owner, b.Through(k.Contract))
 		}
-		delete(snapshot, cAdd)
+		delete(snapshot, k.Contract)
 	}
 }
@@ -0,0 +1,78 @@
+package cw3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	util "github.com/terra-money/core/app/export/util"
+	wasmtypes "github.com/terra-money/core/x/wasm/types"
+)
+
+// membersPageLimit is the largest page cw3 and cw4 contracts return
+const membersPageLimit = 30
+
+// CurrentVoters returns the voters of a multisig or the members of a cw4 group
+// from contract state. Flex multisigs list the members of their group. A fixed
+// multisig that rejects list_voters, or an unclassified contract, falls back to
+// the voters of its init message.
+func CurrentVoters(ctx context.Context, qs wasmtypes.QueryServer, ci wasmtypes.ContractInfo, typ util.ContractType) ([]Voter, error) {
+	switch typ {
+	case util.ContractCW3Fixed:
+		voters, err := listVoters(ctx, qs, ci.Address, "list_voters", "voters")
+		if err == nil {
+			return voters, nil
+		}
+		if voters := initVoters(ci); len(voters) != 0 {
+			return voters, nil
+		}
+		return nil, err
+	case util.ContractCW3Flex:
+		return listVoters(ctx, qs, ci.Address, "list_voters", "voters")
+	case util.ContractCW4Group:
+		return listVoters(ctx, qs, ci.Address, "list_members", "members")
+	default:
+		if voters := initVoters(ci); len(voters) != 0 {
+			return voters, nil
+		}
+		return nil, fmt.Errorf("%s is not a multisig or group", typ)
+	}
+}
+
+// initVoters returns the voters listed in the init message of a contract.
+func initVoters(ci wasmtypes.ContractInfo) []Voter {
+	var initmsg Cw3InitMsg
+	if err := json.Unmarshal(ci.InitMsg, &initmsg); err != nil {
+		return nil
+	}
+	return initmsg.Voters
+}
+
+// listVoters pages through a list_voters or list_members query.
+func listVoters(ctx context.Context, qs wasmtypes.QueryServer, contract string, query string, field string) ([]Voter, error) {
+	var voters []Voter
+	startAfter := ""
+	for {
+		msg := map[string]interface{}{"limit": membersPageLimit}
+		if startAfter != "" {
+			msg["start_after"] = startAfter
+		}
+		queryMsg, err := json.Marshal(map[string]interface{}{query: msg})
+		if err != nil {
+			return nil, err
+		}
+		var res map[string][]Voter
+		if err := util.ContractQuery(ctx, qs, &wasmtypes.QueryContractStoreRequest{
+			ContractAddress: contract,
+			QueryMsg:        queryMsg,
+		}, &res); err != nil {
+			return nil, err
+		}
+		page := res[field]
+		voters = append(voters, page...)
+		if len(page) < membersPageLimit {
+			return voters, nil
+		}
+		startAfter = page[len(page)-1].Address
+	}
+}
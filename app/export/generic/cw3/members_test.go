@@ -0,0 +1,68 @@
+package cw3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	util "github.com/terra-money/core/app/export/util"
+	wasmtypes "github.com/terra-money/core/x/wasm/types"
+)
+
+// groupQuerier answers list_members from a fixed member list.
+type groupQuerier struct {
+	wasmtypes.QueryServer
+	members []Voter
+}
+
+func (q groupQuerier) ContractStore(_ context.Context, req *wasmtypes.QueryContractStoreRequest) (*wasmtypes.QueryContractStoreResponse, error) {
+	var msg struct {
+		ListMembers *struct {
+			StartAfter string `json:"start_after"`
+			Limit      int    `json:"limit"`
+		} `json:"list_members"`
+	}
+	if err := json.Unmarshal(req.QueryMsg, &msg); err != nil || msg.ListMembers == nil {
+		return nil, fmt.Errorf("unknown query %s", req.QueryMsg)
+	}
+	var page []Voter
+	for _, m := range q.members {
+		if m.Address > msg.ListMembers.StartAfter && len(page) < msg.ListMembers.Limit {
+			page = append(page, m)
+		}
+	}
+	res, err := json.Marshal(map[string][]Voter{"members": page})
+	return &wasmtypes.QueryContractStoreResponse{QueryResult: res}, err
+}
+
+func TestCurrentVotersPagesThroughGroup(t *testing.T) {
+	var members []Voter
+	for i := 0; i < membersPageLimit+5; i++ {
+		members = append(members, Voter{Address: fmt.Sprintf("terra1member%03d", i), Weight: 1})
+	}
+	qs := groupQuerier{members: members}
+
+	voters, err := CurrentVoters(context.Background(), qs, wasmtypes.ContractInfo{Address: "terra1group"}, util.ContractCW4Group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voters) != len(members) {
+		t.Fatalf("expected %d members, got %d", len(members), len(voters))
+	}
+
+	// a fixed multisig without list_voters falls back to its init message
+	ci := wasmtypes.ContractInfo{Address: "terra1multisig", InitMsg: []byte(`{"voters":[{"addr":"terra1voter","weight":2}]}`)}
+	voters, err = CurrentVoters(context.Background(), qs, ci, util.ContractCW3Fixed)
+	if err != nil || len(voters) != 1 || voters[0].Weight != 2 {
+		t.Fatalf("unexpected voters %+v, %v", voters, err)
+	}
+	if _, err := CurrentVoters(context.Background(), qs, ci, util.ContractCW3Flex); err == nil {
+		t.Fatal("expected a flex multisig without list_voters to fail")
+	}
+	// unclassified contracts are split by the voters of their init message
+	voters, err = CurrentVoters(context.Background(), qs, ci, util.ContractUnknown)
+	if err != nil || len(voters) != 1 {
+		t.Fatalf("unexpected voters %+v, %v", voters, err)
+	}
+}
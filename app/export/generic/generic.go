@@ -20,6 +20,13 @@ func ExportVestingContracts(app *terra.TerraApp, bl util.Blacklist) (util.Snapsh
 	logger.Info("Getting all contract info...")
 	common.IterateAllContracts(sdk.UnwrapSDKContext(ctx), app.WasmKeeper, contractsMap)
 
+	logger.Info("Classifying contracts...")
+	classes, err := util.ClassifyContracts(app, contractsMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	util.ContractClasses = classes
+
 	// handle vesting
 	if vestingBalance, err := vesting.ExportVestingContracts(app, contractsMap, bl); err != nil {
 		panic(err)
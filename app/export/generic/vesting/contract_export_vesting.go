@@ -34,7 +34,7 @@ type (
 	}
 )
 
-// ExportVestingContracts look for ALL contracts that implements vesting_info key
+// ExportVestingContracts exports every contract classified as vesting
 func ExportVestingContracts(app *terra.TerraApp, contractsMap common.ContractsMap, bl util.Blacklist) (util.SnapshotBalanceAggregateMap, error) {
 
 	ctx := util.PrepCtx(app)
@@ -42,7 +42,7 @@ func ExportVestingContracts(app *terra.TerraApp, contractsMap common.ContractsMa
 
 	var finalBalance util.SnapshotBalanceAggregateMap
 
-	for contractAddr, _ := range contractsMap {
+	for _, contractAddr := range util.ContractClasses.ContractsOf(util.ContractVesting) {
 		vesting, isVesting := checkIfVesting(ctx, qs, app.WasmKeeper, contractAddr)
 
 		// skip if not vesting
@@ -91,7 +91,7 @@ func handleSingleVesting(vestingInfo *SingleVestingInfo) util.SnapshotBalanceAgg
 		denom = vestingInfo.VestingDenom.Cw20.String()
 	}
 
-	utilDenom, ok := coalesceToBalanceDenom(denom)
+	utilDenom, ok := util.BalanceDenom(denom)
 	if !ok {
 		return nil
 	}
@@ -105,28 +105,3 @@ func handleSingleVesting(vestingInfo *SingleVestingInfo) util.SnapshotBalanceAgg
 		},
 	}
 }
-
-func coalesceToBalanceDenom(assetName string) (string, bool) {
-	switch assetName {
-	case "uusd":
-		return util.DenomUST, true
-	case "uluna":
-		return util.DenomLUNA, true
-	case util.AddressBLUNA:
-		return util.DenomBLUNA, true
-	case util.AddressSTLUNA:
-		return util.DenomSTLUNA, true
-	case util.AddressCLUNA:
-		return util.DenomCLUNA, true
-	case util.AddressPLUNA:
-		return util.DenomPLUNA, true
-	case util.AddressNLUNA:
-		return util.DenomNLUNA, true
-	case util.AddressSTEAK:
-		return util.DenomSTEAK, true
-	case util.AddressLUNAX:
-		return util.DenomLUNAX, true
-	}
-
-	return "", false
-}
@@ -0,0 +1,9 @@
+package glow
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("glow", ExportContract, nil))
+}
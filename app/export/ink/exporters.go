@@ -0,0 +1,9 @@
+package ink
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("ink", ExportContract, nil))
+}
@@ -0,0 +1,10 @@
+package kinetic
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewCompounderExporter("kinetic-lockdrop-lps", ExportKineticLpHoldings, nil))
+	util.RegisterExporter(util.NewSBAExporter("kinetic", ExportKinetic, nil))
+}
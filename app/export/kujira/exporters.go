@@ -0,0 +1,9 @@
+package kujira
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("kujira", ExportKujiraVault, Audit).WithVersion(2))
+}
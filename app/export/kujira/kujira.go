@@ -1,10 +1,9 @@
 package kujira
 
 import (
-	"encoding/json"
-
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/storage"
 	"github.com/terra-money/core/app/export/util"
 )
 
@@ -18,30 +17,28 @@ const (
 func ExportKujiraVault(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanceAggregateMap, error) {
 	app.Logger().Info("Exporting Kujira vaults")
 	ctx := util.PrepCtx(app)
-	prefix := util.GeneratePrefix("bid")
-	vaultAddr, err := sdk.AccAddressFromBech32(KujiraAUstVault)
+	store, err := storage.NewContractStore(ctx, app.WasmKeeper, KujiraAUstVault)
 	if err != nil {
 		return nil, err
 	}
 
 	balances := make(map[string]sdk.Int)
-	app.WasmKeeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(ctx), vaultAddr, prefix, func(key, value []byte) bool {
+	err = storage.NewMap("bid", storage.KeyBytes).Range(store, func(_ storage.Keys, value storage.Value) error {
 		var bid struct {
 			Bidder       string  `json:"bidder"`
 			Amount       sdk.Int `json:"amount"`
 			ExchangeRate sdk.Dec `json:"prev_exchange_rate"`
 		}
-		err := json.Unmarshal(value, &bid)
-		if err != nil {
-			panic(err)
+		if err := value.JSON(&bid); err != nil {
+			return err
 		}
 		if bid.Amount.IsZero() {
-			return false
+			return nil
 		}
 
 		bidderAddr, err := util.AccAddressFromBase64(bid.Bidder)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
 		if balances[bidderAddr.String()].IsNil() {
@@ -49,8 +46,11 @@ func ExportKujiraVault(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBal
 		} else {
 			balances[bidderAddr.String()] = balances[bidderAddr.String()].Add(bid.Amount)
 		}
-		return false
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	snapshot := make(util.SnapshotBalanceAggregateMap)
 	bl.RegisterAddress(util.DenomAUST, KujiraAUstVault)
@@ -58,7 +58,9 @@ func ExportKujiraVault(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBal
 	return snapshot, nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+var auditAUST = util.NewInvariant("aUST in vault", util.SeverityWarn, 100000000)
+
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
 	vaultBalance, err := util.GetCW20Balance(ctx, q, util.AUST, KujiraAUstVault)
@@ -68,6 +70,6 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 	// Small rounding error (.00006%) here due to the way Kujira saves amount of aUST deposited
 	// When converting aUST to UST, the anchor exchange rate is used instead of
 	// listening to the hook of the new UST balance
-	util.AlmostEqual("kujira aUST", vaultBalance, snapshot.SumOfDenom(util.DenomAUST), sdk.NewInt(100000000))
+	audit.Check(auditAUST, vaultBalance, snapshot.SumOfDenom(util.DenomAUST))
 	return nil
 }
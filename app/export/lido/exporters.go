@@ -0,0 +1,19 @@
+package lido
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterHub(util.DenomBLUNA, LidoHub)
+	util.RegisterHub(util.DenomSTLUNA, LidoHub)
+	util.RegisterExporter(util.NewResolverExporter("lido-holders", ExportBSTLunaHolders).
+		Consumes(util.DenomConversion(util.DenomNLUNA, util.DenomBLUNA)).
+		Produces(util.DenomHolders(util.DenomBLUNA), util.DenomHolders(util.DenomSTLUNA)))
+	util.RegisterExporter(util.NewAuditedResolverExporter("lido-rewards", ExportLidoRewards).
+		Consumes(util.DenomHolders(util.DenomBLUNA), util.DenomHolders(util.DenomSTLUNA)).
+		Produces("lido rewards"))
+	util.RegisterExporter(util.NewResolverExporter("lido-luna", ResolveLidoLuna).
+		Consumes("lido rewards").
+		Produces(util.DenomConversion(util.DenomBLUNA, util.DenomLUNA), util.DenomConversion(util.DenomSTLUNA, util.DenomLUNA)))
+}
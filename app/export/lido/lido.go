@@ -67,16 +67,10 @@ func ResolveLidoLuna(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregate
 	for _, sbs := range snapshot {
 		for i, sb := range sbs {
 			if sb.Denom == util.DenomBLUNA {
-				sbs[i] = util.SnapshotBalance{
-					Denom:   util.DenomLUNA,
-					Balance: lidoState.BLunaExchangeRate.MulInt(sb.Balance).TruncateInt(),
-				}
+				sbs[i] = sb.Convert(util.DenomLUNA, lidoState.BLunaExchangeRate, LidoHub)
 			}
 			if sb.Denom == util.DenomSTLUNA {
-				sbs[i] = util.SnapshotBalance{
-					Denom:   util.DenomLUNA,
-					Balance: lidoState.StLunaExchangeRate.MulInt(sb.Balance).TruncateInt(),
-				}
+				sbs[i] = sb.Convert(util.DenomLUNA, lidoState.StLunaExchangeRate, LidoHub)
 			}
 		}
 	}
@@ -90,7 +84,7 @@ func ResolveLidoLuna(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregate
 	return nil
 }
 
-func ExportLidoRewards(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, bl util.Blacklist) error {
+func ExportLidoRewards(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, bl util.Blacklist, audit *util.Audit) error {
 	app.Logger().Info("Distributing Lido staking rewards")
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
@@ -132,14 +126,8 @@ func ExportLidoRewards(app *terra.TerraApp, snapshot util.SnapshotBalanceAggrega
 		return err
 	}
 
-	err = util.AlmostEqual("stLUNA", snapshot.SumOfDenom(util.DenomSTLUNA), stLunaTotalSupply, sdk.NewInt(100000))
-	if err != nil {
-		app.Logger().Debug(err.Error())
-	}
-	err = util.AlmostEqual("bLUNA", snapshot.SumOfDenom(util.DenomBLUNA), bLunaTotalSupply, sdk.NewInt(100000))
-	if err != nil {
-		app.Logger().Debug(err.Error())
-	}
+	audit.Check(util.NewInvariant("stLUNA holders", util.SeverityWarn, 100000), stLunaTotalSupply, snapshot.SumOfDenom(util.DenomSTLUNA))
+	audit.Check(util.NewInvariant("bLUNA holders", util.SeverityWarn, 100000), bLunaTotalSupply, snapshot.SumOfDenom(util.DenomBLUNA))
 
 	lunaRewards, err := getLunaRewards(ctx, app.BankKeeper)
 	if err != nil {
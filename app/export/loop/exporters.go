@@ -0,0 +1,9 @@
+package loop
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewAuditedExporter("loop", util.KindSBA, ExportLoopLP).WithVersion(5))
+}
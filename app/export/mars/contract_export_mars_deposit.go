@@ -39,13 +39,13 @@ var (
 // 2. Find total supply of maTokens
 // 3. Find balance of assets in bank
 // 4. Assign accounts with assets proportionally
-func ExportContract(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanceAggregateMap, error) {
+func ExportContract(app *terra.TerraApp, bl util.Blacklist, _ map[string]map[string]map[string]sdk.Int, audit *util.Audit) (util.SnapshotBalanceAggregateMap, error) {
 	app.Logger().Info("Exporting MARS")
 	lunaSs, err := ExportMarsDepositLuna(app, bl)
 	if err != nil {
 		return nil, err
 	}
-	ustSs, err := ExportMarsDepositUST(app, bl)
+	ustSs, err := ExportMarsDepositUST(app, bl, audit)
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +57,12 @@ func ExportContract(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanc
 	return marsSs, nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+var (
+	auditUST  = util.NewInvariant("uusd in market and safety fund", util.SeverityError, 1000000)
+	auditLUNA = util.NewInvariant("uluna in market", util.SeverityError, 1000000)
+)
+
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	ctx := util.PrepCtx(app)
 
 	// UST
@@ -70,20 +75,14 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 		return err
 	}
 
-	err = util.AlmostEqual("mars uust audit", ustLockedInBank.Add(ustLockedInSafety), snapshot.SumOfDenom(util.DenomUST), sdk.NewInt(1000000))
-	if err != nil {
-		return err
-	}
+	audit.Check(auditUST, ustLockedInBank.Add(ustLockedInSafety), snapshot.SumOfDenom(util.DenomUST))
 
 	// Luna
 	lunaLockedInBank, err := util.GetNativeBalance(ctx, app.BankKeeper, util.DenomLUNA, marsMarket)
 	if err != nil {
 		return err
 	}
-	err = util.AlmostEqual("mars uluna audit", lunaLockedInBank, snapshot.SumOfDenom(util.DenomLUNA), sdk.NewInt(1000000))
-	if err != nil {
-		return err
-	}
+	audit.Check(auditLUNA, lunaLockedInBank, snapshot.SumOfDenom(util.DenomLUNA))
 	return nil
 }
 
@@ -124,7 +123,7 @@ func ExportMarsDepositLuna(app *terra.TerraApp, bl util.Blacklist) (util.Snapsho
 	return snapshot, nil
 }
 
-func ExportMarsDepositUST(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanceAggregateMap, error) {
+func ExportMarsDepositUST(app *terra.TerraApp, bl util.Blacklist, audit *util.Audit) (util.SnapshotBalanceAggregateMap, error) {
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
 	logger := app.Logger()
@@ -159,10 +158,7 @@ func ExportMarsDepositUST(app *terra.TerraApp, bl util.Blacklist) (util.Snapshot
 	}
 	delete(balances, marsLockDrop)
 
-	err = util.AlmostEqual("mars aUST", util.Sum(balances), totalSupply, sdk.NewInt(10000))
-	if err != nil {
-		panic(err)
-	}
+	audit.Check(util.NewInvariant("maUST holders", util.SeverityError, 10000), totalSupply, util.Sum(balances))
 
 	// Split the remaining funds in the bank
 	sum := sdk.NewInt(0)
@@ -221,35 +217,39 @@ func ExportMarsSafetyFund(app *terra.TerraApp, bl util.Blacklist) (util.Snapshot
 // Get eventual ownership of LP tokens in the Field of Mars (leveraged yield farming) contracts
 // 1. Get the LP token contract addr
 // 2. List all positions recurrsively
-// 3. Find how much LP tokens are deposited at the astroport generator
-// 4. Split the LP based on bond_unit and create a holding map with format {farm: {"lp_token_addr": {"wallet_addr": "amount"}}}
+// 3. Split the LP based on bond_unit and create a holding map with format {farm: {"lp_token_addr": {"wallet_addr": "amount"}}}
 func ExportFieldOfMarsLpTokens(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) (map[string]map[string]map[string]sdk.Int, error) {
 	app.Logger().Info("Exporting Field of Mars")
 	q := util.PrepWasmQueryServer(app)
 	ctx := util.PrepCtx(app)
 	holdings := make(map[string]map[string]map[string]sdk.Int)
-	lpTokenFieldMap := make(map[string]string)
 	for _, fieldContract := range marsFields {
 		holding := make(map[string]map[string]sdk.Int)
-		err := getFieldOfMarsPositions(ctx, q, fieldContract, holding, lpTokenFieldMap)
+		err := getFieldOfMarsPositions(ctx, q, fieldContract, holding)
 		holdings[fieldContract] = holding
 		if err != nil {
 			app.Logger().Error(err.Error())
 			return nil, err
 		}
 	}
+	return holdings, nil
+}
 
-	for _, holding := range holdings {
+// AuditFieldOfMars checks that the positions of every field add up to the LP
+// tokens it deposits in the astroport generator.
+func AuditFieldOfMars(app *terra.TerraApp, lpHoldings map[string]map[string]map[string]sdk.Int, audit *util.Audit) error {
+	q := util.PrepWasmQueryServer(app)
+	ctx := util.PrepCtx(app)
+	for fieldContract, holding := range lpHoldings {
 		for lpToken, h := range holding {
-			fieldContract := lpTokenFieldMap[lpToken]
-			err := auditAstroportLpBalances(ctx, q, astroportGenerator, lpToken, h, fieldContract)
+			deposit, err := getAstroportGeneratorDeposit(ctx, q, astroportGenerator, lpToken, fieldContract)
 			if err != nil {
-				return nil, err
+				return err
 			}
-
+			audit.Check(util.NewInvariant(fmt.Sprintf("field %s lp %s positions", fieldContract, lpToken), util.SeverityError, 100000), deposit, util.Sum(h))
 		}
 	}
-	return holdings, nil
+	return nil
 }
 
 func ExportMarsAuctionLpHolders(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) (map[string]map[string]map[string]sdk.Int, error) {
@@ -290,23 +290,6 @@ func ExportMarsAuctionLpHolders(app *terra.TerraApp, snapshot util.SnapshotBalan
 	return lpHolders, nil
 }
 
-func auditAstroportLpBalances(ctx context.Context, q wasmtypes.QueryServer, astroportGenerator string, lpToken string, holdings map[string]sdk.Int, vaultAddr string) error {
-	astroportDeposits, err := getAstroportGeneratorDeposit(ctx, q, astroportGenerator, lpToken, vaultAddr)
-	if err != nil {
-		return err
-	}
-	totalHolding := sdk.NewInt(0)
-	for _, balance := range holdings {
-		totalHolding = totalHolding.Add(balance)
-	}
-
-	err = util.AlmostEqual("mars farm", totalHolding, astroportDeposits, sdk.NewInt(100000))
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func getAstroportGeneratorDeposit(ctx context.Context, q wasmtypes.QueryServer, astroportGenerator string, lpToken string, user string) (sdk.Int, error) {
 	query := fmt.Sprintf("{\"deposit\": {\"user\": \"%s\", \"lp_token\": \"%s\"}}", user, lpToken)
 	var amount sdk.Int
@@ -325,7 +308,6 @@ func getFieldOfMarsPositions(
 	q wasmtypes.QueryServer,
 	fieldContract string,
 	holdings map[string]map[string]sdk.Int,
-	lpTokenFieldMap map[string]string,
 ) error {
 	var fieldConfig struct {
 		PrimaryPair struct {
@@ -339,7 +321,6 @@ func getFieldOfMarsPositions(
 	if err != nil {
 		return err
 	}
-	lpTokenFieldMap[fieldConfig.PrimaryPair.LiquidityToken] = fieldContract
 
 	var fieldState struct {
 		TotalBondUnits sdk.Int `json:"total_bond_units"`
@@ -0,0 +1,11 @@
+package mars
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewCompounderExporter("mars-field", ExportFieldOfMarsLpTokens, AuditFieldOfMars).OnlyFor(util.Snapshot(util.PreAttack)))
+	util.RegisterExporter(util.NewCompounderExporter("mars-auction", ExportMarsAuctionLpHolders, nil))
+	util.RegisterExporter(util.NewAuditedExporter("mars", util.KindSBA, ExportContract).WithSnapshotAudit(Audit).WithVersion(3))
+}
@@ -43,7 +43,7 @@ func ExportMirrorLpStakers(app *terra.TerraApp, snapshot util.SnapshotBalanceAgg
 	}, nil
 }
 
-func AuditCompounders(app *terra.TerraApp, compounders map[string]map[string]map[string]sdk.Int) error {
+func AuditCompounders(app *terra.TerraApp, compounders map[string]map[string]map[string]sdk.Int, audit *util.Audit) error {
 	app.Logger().Info("Audit -- Mirror Compounders")
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
@@ -63,10 +63,7 @@ func AuditCompounders(app *terra.TerraApp, compounders map[string]map[string]map
 			usersTotalBalance = usersTotalBalance.Add(userBalance)
 		}
 
-		err = util.AlmostEqual(lp, contractBalance, usersTotalBalance, sdk.NewInt(1000000))
-		if err != nil {
-			return err
-		}
+		audit.Check(util.NewInvariant(fmt.Sprintf("lp %s staked", lp), util.SeverityError, 1000000), contractBalance, usersTotalBalance)
 	}
 
 	return nil
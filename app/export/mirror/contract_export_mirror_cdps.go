@@ -73,7 +73,7 @@ func ExportMirrorCdps(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBala
 	return snapshot, nil
 }
 
-func AuditCdps(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+func AuditCdps(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	app.Logger().Info("Audit -- Mirror")
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
@@ -110,21 +110,16 @@ func AuditCdps(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) e
 			return err
 		}
 
+		inv := util.NewInvariant(fmt.Sprintf("%s collateral", util.MapContractToDenom(denom)), util.SeverityError, 1000000)
 		if denom == addressLunaX {
 			// compare with recorded total
-			err = util.AlmostEqual(denom, contractBalance, totalLunaXAmount, sdk.NewInt(1000000))
+			audit.Check(inv, contractBalance, totalLunaXAmount)
 		} else if denom == util.DenomLUNA {
 			// need to include the converted lunaX
 			lunaXValue := lunaXExchangeRate.MulInt(totalLunaXAmount).TruncateInt()
-			expectedLunaBalance := snapshot.SumOfDenom(denom).Sub(lunaXValue)
-			err = util.AlmostEqual(denom, contractBalance, expectedLunaBalance, sdk.NewInt(1000000))
+			audit.Check(inv, contractBalance, snapshot.SumOfDenom(denom).Sub(lunaXValue))
 		} else {
-			sumOfSnapshot := snapshot.SumOfDenom(util.MapContractToDenom(denom))
-			err = util.AlmostEqual(denom, contractBalance, sumOfSnapshot, sdk.NewInt(1000000))
-		}
-
-		if err != nil {
-			return err
+			audit.Check(inv, contractBalance, snapshot.SumOfDenom(util.MapContractToDenom(denom)))
 		}
 	}
 
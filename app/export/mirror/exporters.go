@@ -0,0 +1,11 @@
+package mirror
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewCompounderExporter("mirror", ExportMirrorLpStakers, AuditCompounders))
+	util.RegisterExporter(util.NewSBAExporter("mirror-cdp", ExportMirrorCdps, AuditCdps))
+	util.RegisterExporter(util.NewSBAExporter("mirror-limit-order", ExportLimitOrderContract, AuditLOs))
+}
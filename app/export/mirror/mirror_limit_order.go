@@ -40,7 +40,9 @@ func ExportLimitOrderContract(
 	return snapshot, nil
 }
 
-func AuditLOs(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+var auditLimitOrderUST = util.NewInvariant("uusd in limit orders", util.SeverityError, 10000)
+
+func AuditLOs(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	app.Logger().Info("Audit -- Mirro LO")
 	ctx := util.PrepCtx(app)
 
@@ -49,11 +51,7 @@ func AuditLOs(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) er
 		return err
 	}
 
-	sumOfSnapshot := snapshot.SumOfDenom(util.DenomUST)
-	err = util.AlmostEqual(util.DenomUST, contractBalance, sumOfSnapshot, sdk.NewInt(10000))
-	if err != nil {
-		return err
-	}
+	audit.Check(auditLimitOrderUST, contractBalance, snapshot.SumOfDenom(util.DenomUST))
 
 	return nil
 }
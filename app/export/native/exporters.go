@@ -0,0 +1,10 @@
+package native
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("bonded-luna", ExportAllBondedLuna, nil))
+	util.RegisterExporter(util.NewSBAExporter("native-balance", ExportAllNativeBalances, nil))
+}
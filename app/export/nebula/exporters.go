@@ -0,0 +1,9 @@
+package nebula
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("nebula", ExportNebulaCommunityFund, nil))
+}
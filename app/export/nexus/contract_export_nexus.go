@@ -136,10 +136,7 @@ func ResolveToBLuna(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateM
 	for _, sbs := range snapshot {
 		for i, sb := range sbs {
 			if sb.Denom == util.DenomNLUNA {
-				sbs[i] = util.SnapshotBalance{
-					Denom:   util.DenomBLUNA,
-					Balance: nAssetTobAssetRatio.MulInt(sb.Balance).TruncateInt(),
-				}
+				sbs[i] = sb.Convert(util.DenomBLUNA, nAssetTobAssetRatio, AddressNLUNA)
 			}
 		}
 	}
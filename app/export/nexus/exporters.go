@@ -0,0 +1,27 @@
+package nexus
+
+import (
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	// nLUNA pairs must be blacklisted before nexus reads the blacklist
+	util.RegisterExporter(util.NewExporter("nexus", util.KindSBA, exportNexus).
+		Consumes(util.SnapshotOf("astroport"), util.SnapshotOf("terraswap")).
+		WithVersion(2))
+	util.RegisterExporter(util.NewResolverExporter("nexus-nluna", ResolveToBLuna).
+		Produces(util.DenomConversion(util.DenomNLUNA, util.DenomBLUNA)))
+}
+
+// exportNexus reads nLUNA held through LPs from the astroport snapshot. Like
+// every SBA exporter it is cached, keyed on the astroport and terraswap outputs
+// it consumes; the nLUNA blacklist it reads comes from their blacklist deltas
+// and the profile's extra blacklist.
+func exportNexus(app *terra.TerraApp, bl util.Blacklist, state *util.ExportState) (util.ExportOutput, error) {
+	snapshot, err := ExportNexus(app, state.Output("astroport").Snapshot, bl)
+	if err != nil {
+		return util.ExportOutput{}, err
+	}
+	return util.ExportOutput{Snapshot: snapshot}, util.SaveToFile(app, snapshot, "nexus")
+}
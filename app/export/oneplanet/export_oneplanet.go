@@ -3,8 +3,6 @@ package oneplanet
 import (
 	"context"
 
-	sdk "github.com/cosmos/cosmos-sdk/types"
-
 	terra "github.com/terra-money/core/app"
 
 	"github.com/terra-money/core/app/export/util"
@@ -102,7 +100,12 @@ func getOwnerIfExists(ctx context.Context, q wasmtypes.QueryServer, contractAddr
 	return nil, initMsg.Owner
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+var (
+	auditUST  = util.NewInvariant("uusd in pool", util.SeverityWarn, 1000000)
+	auditLUNA = util.NewInvariant("uluna in pool", util.SeverityWarn, 1000000)
+)
+
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	app.Logger().Info("Audit -- OnePlanet")
 	ctx := util.PrepCtx(app)
 
@@ -111,18 +114,14 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 		return err
 	}
 
-	if err := util.AlmostEqual(util.DenomUST, ustBalance, snapshot.SumOfDenom(util.DenomUST), sdk.NewInt(1000000)); err != nil {
-		return err
-	}
+	audit.Check(auditUST, ustBalance, snapshot.SumOfDenom(util.DenomUST))
 
 	lunaBalance, err := util.GetNativeBalance(ctx, app.BankKeeper, util.DenomLUNA, opLUNA.Address)
 	if err != nil {
 		return err
 	}
 
-	if err := util.AlmostEqual(util.DenomLUNA, lunaBalance, snapshot.SumOfDenom(util.DenomLUNA), sdk.NewInt(1000000)); err != nil {
-		return err
-	}
+	audit.Check(auditLUNA, lunaBalance, snapshot.SumOfDenom(util.DenomLUNA))
 
 	return nil
 }
@@ -0,0 +1,9 @@
+package oneplanet
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("oneplanet", ExportHoldings, Audit).WithVersion(2))
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/terra-money/core/app/export/util"
+)
+
+const (
+	OverlapSeveralExporters = "looked through by several exporters"
+	OverlapNotBlacklisted   = "holds a balance that is not blacklisted"
+)
+
+// Overlap is a contract whose holdings may be counted twice: either several
+// exporters attributed them to users, or the contract keeps a balance next to
+// what an exporter attributed to users.
+type Overlap struct {
+	Contract  string   `json:"contract"`
+	Denom     string   `json:"denom"`
+	Kind      string   `json:"kind"`
+	Exporters []string `json:"exporters"`
+	// Balance is what the contract holds in the merged snapshot before blacklisting
+	Balance sdk.Int `json:"balance"`
+}
+
+// lookedThrough returns, by contract and denom, the exporters that attributed
+// the holdings of a contract to its users: the addresses each exporter
+// blacklisted, and the vaults of lp-compounders, by lp token.
+func lookedThrough(state *util.ExportState) map[string]map[string][]string {
+	seen := make(map[string]map[string][]string)
+	add := func(contract, denom, exporter string) {
+		if seen[contract] == nil {
+			seen[contract] = make(map[string][]string)
+		}
+		seen[contract][denom] = appendMissing(seen[contract][denom], exporter)
+	}
+	for _, name := range state.OutputNames() {
+		out := state.Output(name)
+		for denom, addrs := range out.BlacklistDelta {
+			for _, addr := range addrs {
+				add(addr, denom, name)
+			}
+		}
+		for vault, lps := range out.LpHoldings {
+			for lp := range lps {
+				add(vault, lp, name)
+			}
+		}
+	}
+	return seen
+}
+
+// DetectOverlaps checks the merged snapshot, before bl is applied, for funds
+// counted twice across exporters.
+func DetectOverlaps(state *util.ExportState, merged util.SnapshotBalanceAggregateMap, bl util.Blacklist) []Overlap {
+	var overlaps []Overlap
+	blacklisted := make(map[string]map[string]bool)
+	for contract, denoms := range lookedThrough(state) {
+		var all []string
+		for denom, exporters := range denoms {
+			all = appendMissing(all, exporters...)
+			if len(exporters) > 1 {
+				sort.Strings(exporters)
+				overlaps = append(overlaps, Overlap{
+					Contract:  contract,
+					Denom:     denom,
+					Kind:      OverlapSeveralExporters,
+					Exporters: exporters,
+					Balance:   heldBy(merged, contract, denom),
+				})
+			}
+		}
+		sort.Strings(all)
+
+		for _, denom := range denomsHeld(merged, contract) {
+			if blacklisted[denom] == nil {
+				blacklisted[denom] = bl.GetAddressesByDenomMap(denom)
+			}
+			if balance := heldBy(merged, contract, denom); !balance.IsZero() && !blacklisted[denom][contract] {
+				overlaps = append(overlaps, Overlap{
+					Contract:  contract,
+					Denom:     denom,
+					Kind:      OverlapNotBlacklisted,
+					Exporters: all,
+					Balance:   balance,
+				})
+			}
+		}
+	}
+	sort.Slice(overlaps, func(i, j int) bool {
+		oi, oj := overlaps[i], overlaps[j]
+		if oi.Contract != oj.Contract {
+			return oi.Contract < oj.Contract
+		}
+		if oi.Denom != oj.Denom {
+			return oi.Denom < oj.Denom
+		}
+		return oi.Kind < oj.Kind
+	})
+	return overlaps
+}
+
+func heldBy(s util.SnapshotBalanceAggregateMap, addr string, denom string) sdk.Int {
+	sum := sdk.ZeroInt()
+	for _, sb := range s[addr] {
+		if sb.Denom == denom && !sb.Balance.IsNil() {
+			sum = sum.Add(sb.Balance)
+		}
+	}
+	return sum
+}
+
+func denomsHeld(s util.SnapshotBalanceAggregateMap, addr string) []string {
+	var denoms []string
+	for _, sb := range s[addr] {
+		if !sb.Balance.IsNil() {
+			denoms = appendMissing(denoms, sb.Denom)
+		}
+	}
+	sort.Strings(denoms)
+	return denoms
+}
+
+// auditOverlaps records every overlap in audit and saves them as
+// double-counting.json in folder.
+func auditOverlaps(overlaps []Overlap, audit *util.Audit, folder string) error {
+	for _, o := range overlaps {
+		inv := util.NewInvariant(fmt.Sprintf("%s %s %s", o.Contract, o.Denom, o.Kind), util.SeverityWarn, 1)
+		if o.Kind == OverlapSeveralExporters {
+			// expected one exporter, found several
+			audit.Check(inv, sdk.OneInt(), sdk.NewInt(int64(len(o.Exporters))))
+		} else {
+			audit.Check(inv, sdk.ZeroInt(), o.Balance)
+		}
+	}
+	if overlaps == nil {
+		overlaps = []Overlap{}
+	}
+	out, err := json.MarshalIndent(overlaps, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(folder, "double-counting.json"), out, 0666)
+}
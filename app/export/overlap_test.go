@@ -0,0 +1,36 @@
+package app
+
+import (
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/terra-money/core/app/export/util"
+)
+
+func TestDetectOverlaps(t *testing.T) {
+	state := util.NewExportState(util.Snapshot("test"))
+	state.SetOutput("anchor", util.ExportOutput{BlacklistDelta: util.Blacklist{util.DenomAUST: {"terra1vault"}}})
+	state.SetOutput("kujira", util.ExportOutput{BlacklistDelta: util.Blacklist{util.DenomAUST: {"terra1vault"}}})
+	state.SetOutput("apollo", util.ExportOutput{LpHoldings: map[string]map[string]map[string]sdk.Int{
+		"terra1strat": {"terra1lp": {"terra1user": sdk.NewInt(1)}},
+	}})
+
+	bl := util.Blacklist{}
+	bl.RegisterAddress(util.DenomAUST, "terra1vault")
+	merged := util.SnapshotBalanceAggregateMap{
+		"terra1vault": {{Denom: util.DenomAUST, Balance: sdk.NewInt(100)}},
+		"terra1strat": {{Denom: util.DenomLUNA, Balance: sdk.NewInt(7)}},
+		"terra1user":  {{Denom: util.DenomLUNA, Balance: sdk.NewInt(7)}},
+	}
+
+	overlaps := DetectOverlaps(state, merged, bl)
+	if len(overlaps) != 2 {
+		t.Fatalf("expected 2 overlaps, got %+v", overlaps)
+	}
+	if o := overlaps[0]; o.Contract != "terra1strat" || o.Kind != OverlapNotBlacklisted || !o.Balance.Equal(sdk.NewInt(7)) || o.Exporters[0] != "apollo" {
+		t.Fatalf("unexpected overlap %+v", o)
+	}
+	if o := overlaps[1]; o.Contract != "terra1vault" || o.Kind != OverlapSeveralExporters || len(o.Exporters) != 2 || !o.Balance.Equal(sdk.NewInt(100)) {
+		t.Fatalf("unexpected overlap %+v", o)
+	}
+}
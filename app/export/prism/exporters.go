@@ -0,0 +1,16 @@
+package prism
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterHub(util.DenomCLUNA, PrismVault)
+	util.RegisterExporter(util.NewSBAExporter("prism", ExportContract, Audit).WithVersion(2))
+	util.RegisterExporter(util.NewSBAExporter("prism-limit-order", ExportLimitOrderContract, AuditLOs))
+	// prism-luna applies the blacklist, so it runs once lido-luna has registered
+	// its hub; the other LUNA resolvers follow it in a fixed order
+	util.RegisterExporter(util.NewResolverExporter("prism-luna", ResolveToLuna).
+		Consumes(util.DenomConversion(util.DenomBLUNA, util.DenomLUNA), util.DenomConversion(util.DenomSTLUNA, util.DenomLUNA)).
+		Produces(util.DenomConversion(util.DenomPLUNA, util.DenomCLUNA), util.DenomConversion(util.DenomCLUNA, util.DenomLUNA)))
+}
@@ -104,7 +104,13 @@ func ExportContract(
 	return snapshot, nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+var (
+	auditUnbondedLUNA = util.NewInvariant("unbonded uluna in vault", util.SeverityWarn, 100000)
+	auditCLUNA        = util.NewInvariant("cLUNA supply", util.SeverityWarn, 200000)
+	auditPLUNA        = util.NewInvariant("pLUNA supply", util.SeverityError, 200000)
+)
+
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	app.Logger().Info("Audit -- Prism")
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
@@ -114,28 +120,21 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 	if err != nil {
 		return err
 	}
-	util.AlmostEqual("unbonded luna prism", lunaInVault, snapshot.SumOfDenom(util.DenomLUNA), sdk.NewInt(100000))
+	audit.Check(auditUnbondedLUNA, lunaInVault, snapshot.SumOfDenom(util.DenomLUNA))
 
 	// check cluna supply
 	cLunaSupply, err := util.GetCW20TotalSupply(ctx, q, PrismCLuna)
 	if err != nil {
 		return err
 	}
-	err = util.AlmostEqual("cLuna doesn't match", cLunaSupply, snapshot.SumOfDenom(util.DenomCLUNA), sdk.NewInt(200000))
-	if err != nil {
-		// fmt.Println(err)
-		// return err
-	}
+	audit.Check(auditCLUNA, cLunaSupply, snapshot.SumOfDenom(util.DenomCLUNA))
 
 	// check pluna supply
 	pLunaSupply, err := util.GetCW20TotalSupply(ctx, q, PrismPLuna)
 	if err != nil {
 		return err
 	}
-	err = util.AlmostEqual("pLuna doesn't match", pLunaSupply, snapshot.SumOfDenom(util.DenomPLUNA), sdk.NewInt(200000))
-	if err != nil {
-		return err
-	}
+	audit.Check(auditPLUNA, pLunaSupply, snapshot.SumOfDenom(util.DenomPLUNA))
 
 	return nil
 }
@@ -156,10 +155,7 @@ func ResolveToLuna(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMa
 	for _, sbs := range snapshot {
 		for i, sb := range sbs {
 			if sb.Denom == util.DenomCLUNA {
-				sbs[i] = util.SnapshotBalance{
-					Denom:   util.DenomLUNA,
-					Balance: prismState.ExchangeRate.MulInt(sb.Balance).TruncateInt(),
-				}
+				sbs[i] = sb.Convert(util.DenomLUNA, prismState.ExchangeRate, PrismVault)
 			}
 		}
 	}
@@ -170,10 +166,7 @@ func swapPLunaToCLuna(snapshot util.SnapshotBalanceAggregateMap) {
 	for _, sbs := range snapshot {
 		for i, sb := range sbs {
 			if sb.Denom == util.DenomPLUNA {
-				sbs[i] = util.SnapshotBalance{
-					Denom:   util.DenomCLUNA,
-					Balance: sb.Balance,
-				}
+				sbs[i] = sb.Convert(util.DenomCLUNA, sdk.OneDec(), PrismVault)
 			}
 		}
 	}
@@ -66,7 +66,7 @@ func ExportLimitOrderContract(
 	return snapshot, nil
 }
 
-func AuditLOs(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+func AuditLOs(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	app.Logger().Info("Audit -- Prism LO")
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
@@ -81,10 +81,8 @@ func AuditLOs(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) er
 		if err != nil {
 			return err
 		}
-		err = util.AlmostEqual(denom, contractBalance, snapshot.SumOfDenom(util.MapContractToDenom(denom)), sdk.NewInt(10000))
-		if err != nil {
-			return err
-		}
+		audit.Check(util.NewInvariant(fmt.Sprintf("%s in limit orders", util.MapContractToDenom(denom)), util.SeverityError, 10000),
+			contractBalance, snapshot.SumOfDenom(util.MapContractToDenom(denom)))
 	}
 
 	return nil
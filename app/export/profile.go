@@ -0,0 +1,173 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/terra-money/core/app/export/util"
+)
+
+const (
+	// AUSTConvert pays out aUST balances as UST
+	AUSTConvert = "convert"
+	// AUSTKeep leaves aUST balances as they are
+	AUSTKeep = "keep"
+)
+
+// Profile describes a snapshot: the height it is taken at and the policies
+// applied to it. Profiles are read from YAML or JSON files.
+type Profile struct {
+	Height int64 `json:"height" yaml:"height"`
+	// BlockTime overrides the RFC3339 time of the block at Height, which is
+	// otherwise read from the node's block store
+	BlockTime    string        `json:"block_time,omitempty" yaml:"block_time,omitempty"`
+	SnapshotType util.Snapshot `json:"snapshot_type" yaml:"snapshot_type"`
+	// Denoms lists the denoms kept in the final balances; empty keeps all
+	Denoms []string `json:"denoms,omitempty" yaml:"denoms,omitempty"`
+	// AUSTToUST is AUSTConvert or AUSTKeep
+	AUSTToUST string `json:"aust_to_ust" yaml:"aust_to_ust"`
+	// ExtraBlacklist lists addresses excluded from the snapshot, by denom
+	ExtraBlacklist map[string][]string `json:"extra_blacklist,omitempty" yaml:"extra_blacklist,omitempty"`
+	// WhitelistedContracts keep their balances in the final snapshot
+	WhitelistedContracts []string `json:"whitelisted_contracts,omitempty" yaml:"whitelisted_contracts,omitempty"`
+	// Protocols turns exporters on or off regardless of the snapshot type
+	Protocols map[string]bool `json:"protocols,omitempty" yaml:"protocols,omitempty"`
+	// Tokens lists cw20 contracts snapshotted as denoms of their own, named
+	// after the contract address and looked through the DEX pools and
+	// terraswap-style staking contracts holding them. Tokens held by other
+	// contracts, such as governance, are listed as unattributed and the final
+	// audit warns of the missing supply
+	Tokens []string `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+}
+
+var builtinProfiles = []Profile{
+	{
+		Height:       7544910,
+		BlockTime:    time.Unix(1651935577, 792).UTC().Format(time.RFC3339Nano),
+		SnapshotType: util.Snapshot(util.PreAttack),
+		AUSTToUST:    AUSTKeep,
+	},
+	{
+		Height:       7790000,
+		BlockTime:    time.Unix(1653583088, 146).UTC().Format(time.RFC3339Nano),
+		SnapshotType: util.Snapshot(util.PostAttack),
+		AUSTToUST:    AUSTConvert,
+	},
+	// test
+	{
+		Height:       7684654,
+		BlockTime:    time.Unix(1652926192, 483).UTC().Format(time.RFC3339Nano),
+		SnapshotType: util.Snapshot(util.PostAttack),
+		AUSTToUST:    AUSTConvert,
+	},
+}
+
+// ProfileFor returns the built-in profile for a height, or a post-attack
+// profile reading its block time from the block store for any other height.
+func ProfileFor(height int64) Profile {
+	for _, p := range builtinProfiles {
+		if p.Height == height {
+			return p
+		}
+	}
+	return Profile{
+		Height:       height,
+		SnapshotType: util.Snapshot(util.PostAttack),
+		AUSTToUST:    AUSTConvert,
+	}
+}
+
+// LoadProfile reads a profile from a .json file, or from YAML otherwise.
+func LoadProfile(path string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	var p Profile
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &p)
+	} else {
+		err = yaml.UnmarshalStrict(data, &p)
+	}
+	if err != nil {
+		return Profile{}, fmt.Errorf("invalid profile %s: %v", path, err)
+	}
+	if p.AUSTToUST == "" {
+		// aUST is only worth converting after the attack
+		p.AUSTToUST = AUSTKeep
+		if p.SnapshotType == util.Snapshot(util.PostAttack) {
+			p.AUSTToUST = AUSTConvert
+		}
+	}
+	return p, p.Validate()
+}
+
+func (p Profile) Validate() error {
+	if p.Height <= 0 {
+		return fmt.Errorf("profile height must be set")
+	}
+	if p.SnapshotType != util.Snapshot(util.PreAttack) && p.SnapshotType != util.Snapshot(util.PostAttack) {
+		return fmt.Errorf("unknown snapshot type %q, expected %s or %s", p.SnapshotType, util.PreAttack, util.PostAttack)
+	}
+	if p.AUSTToUST != AUSTConvert && p.AUSTToUST != AUSTKeep {
+		return fmt.Errorf("unknown aust_to_ust policy %q, expected %s or %s", p.AUSTToUST, AUSTConvert, AUSTKeep)
+	}
+	if _, err := p.blockTime(); err != nil {
+		return err
+	}
+	for name := range p.Protocols {
+		if _, ok := util.GetExporter(name); !ok {
+			return fmt.Errorf("unknown exporter %s in protocols", name)
+		}
+	}
+	for _, token := range p.Tokens {
+		if err := util.ValidateToken(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Profile) blockTime() (time.Time, error) {
+	if p.BlockTime == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, p.BlockTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid block_time %q: %v", p.BlockTime, err)
+	}
+	return t, nil
+}
+
+// enables reports whether the profile runs e, given whether it is enabled for
+// the snapshot type.
+func (p Profile) enables(e util.Exporter) bool {
+	if on, ok := p.Protocols[e.Name()]; ok {
+		return on
+	}
+	return e.Enabled(p.SnapshotType)
+}
+
+// keepsDenom reports whether denom is kept in the final balances. Tracked
+// tokens always are.
+func (p Profile) keepsDenom(denom string) bool {
+	if len(p.Denoms) == 0 {
+		return true
+	}
+	for _, t := range p.Tokens {
+		if t == denom {
+			return true
+		}
+	}
+	for _, d := range p.Denoms {
+		if d == denom {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/terra-money/core/app/export/util"
+)
+
+func TestLoadProfile(t *testing.T) {
+	p, err := LoadProfile("profiles/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Height != 7790000 || p.SnapshotType != util.Snapshot(util.PostAttack) || p.AUSTToUST != AUSTConvert {
+		t.Fatalf("unexpected profile %+v", p)
+	}
+	if builtin := ProfileFor(p.Height); builtin.BlockTime != p.BlockTime {
+		t.Fatalf("example block time %s differs from the built-in %s", p.BlockTime, builtin.BlockTime)
+	}
+	if !p.keepsDenom(util.DenomLUNA) || p.keepsDenom(util.DenomAUST) {
+		t.Fatal("denoms of interest not applied")
+	}
+	aperturePre, _ := util.GetExporter("aperture-pre")
+	if p.enables(aperturePre) {
+		t.Fatal("aperture-pre should be switched off")
+	}
+}
+
+func TestLoadProfileDefaultsAUSTPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profile.json")
+	if err := os.WriteFile(path, []byte(`{"height": 1, "snapshot_type": "preattack"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AUSTToUST != AUSTKeep {
+		t.Fatalf("expected pre-attack profiles to keep aUST, got %s", p.AUSTToUST)
+	}
+}
@@ -0,0 +1,9 @@
+package pylon
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("pylon", ExportContract, Audit).WithVersion(2))
+}
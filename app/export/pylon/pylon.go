@@ -176,7 +176,9 @@ func ExportContract(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanc
 	return snapshot, nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+var auditAUST = util.NewInvariant("aUST deposited in pools", util.SeverityWarn, 100000000000)
+
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	app.Logger().Info("Audit -- Pylon")
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
@@ -197,9 +199,7 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 		totalAUst = totalAUst.Add(config.UstAmount.ToDec().QuoTruncate(aUstER).TruncateInt()).Add(config.AUstAmount)
 	}
 
-	if err := util.AlmostEqual(util.DenomUST, totalAUst, snapshot.SumOfDenom(util.DenomAUST), sdk.NewInt(100000000000)); err != nil {
-		return err
-	}
+	audit.Check(auditAUST, totalAUst, snapshot.SumOfDenom(util.DenomAUST))
 
 	return nil
 }
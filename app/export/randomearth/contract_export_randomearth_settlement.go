@@ -3,9 +3,9 @@ package randomearth
 import (
 	"strings"
 
-	sdk "github.com/cosmos/cosmos-sdk/types"
 	terra "github.com/terra-money/core/app"
 
+	"github.com/terra-money/core/app/export/storage"
 	"github.com/terra-money/core/app/export/util"
 )
 
@@ -23,29 +23,36 @@ func ExportSettlements(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBal
 
 	// Pull users from balances map.
 	// pub const BALANCES: Map<(&[u8], &[u8]), Uint128> = Map::new("balances");
-	prefix := util.GeneratePrefix("balances")
-	delegatorAddr, err := sdk.AccAddressFromBech32(Settlement)
+	balances := storage.NewMap("balances", storage.KeyAddr, storage.KeyBytes)
+	store, err := storage.NewContractStore(ctx, app.WasmKeeper, Settlement)
 	if err != nil {
 		return nil, err
 	}
 
-	app.WasmKeeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(ctx), delegatorAddr, prefix, func(key, value []byte) bool {
+	err = balances.Range(store, func(keys storage.Keys, value storage.Value) error {
 		// We only care about uluna balances. This map also includes NFTs and other holdings.
-		if strings.Contains(string(key), "uluna") {
-			// Filter out characters from start and end of the key.
-			correctedAddress := string(key)[2:46]
-			// Remove quotes from the value and convert to an Int.
-			balance, ok := sdk.NewIntFromString(strings.Trim(string(value), "\""))
-			if ok && !balance.IsZero() {
-				snapshot.AppendOrAddBalance(correctedAddress, util.SnapshotBalance{
-					Denom:   util.DenomLUNA,
-					Balance: balance,
-				})
-			}
+		if !strings.Contains(keys[1].String(), util.DenomLUNA) {
+			return nil
 		}
-
-		return false
+		owner, err := keys[0].Addr()
+		if err != nil {
+			return err
+		}
+		balance, err := value.Int()
+		if err != nil {
+			return err
+		}
+		if !balance.IsZero() {
+			snapshot.AppendOrAddBalance(owner, util.SnapshotBalance{
+				Denom:   util.DenomLUNA,
+				Balance: balance,
+			})
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	bl.RegisterAddress(util.DenomLUNA, Settlement)
 	return snapshot, nil
@@ -0,0 +1,9 @@
+package randomearth
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("radomearth", ExportSettlements, nil).WithVersion(2))
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/util"
+)
+
+const (
+	ChangeConverted        = "converted"
+	ChangeRemovedContracts = "contract holdings removed"
+	ChangeCreated          = "unexplained creation"
+	ChangeDestroyed        = "unexplained destruction"
+)
+
+// conservationTolerance absorbs the rounding of balances converted per holder
+var conservationTolerance = sdk.NewInt(1000000)
+
+// roundingTolerance absorbs the rounding of a stage holding n balances of a
+// denom, each of which may have lost a unit to truncation.
+func roundingTolerance(n int) sdk.Int {
+	return conservationTolerance.AddRaw(int64(n))
+}
+
+// DenomChange is the change of the eligible total of a denom made by a stage.
+type DenomChange struct {
+	Denom       string  `json:"denom"`
+	Before      sdk.Int `json:"before"`
+	After       sdk.Int `json:"after"`
+	Delta       sdk.Int `json:"delta"`
+	Explanation string  `json:"explanation"`
+}
+
+// ReconciledStage holds the totals of a saved snapshot and the changes the
+// stage that saved it made to the previous one.
+type ReconciledStage struct {
+	Stage   string           `json:"stage"`
+	Saved   string           `json:"saved"`
+	Totals  util.StageTotals `json:"totals"`
+	Changes []DenomChange    `json:"changes,omitempty"`
+}
+
+// savedStage is a snapshot of the merged snapshot saved by a stage of the plan.
+type savedStage struct {
+	stage    string
+	exporter util.Exporter
+	file     string
+	// removesContracts marks the stage dropping contract holdings, which converts nothing
+	removesContracts bool
+}
+
+// savedStages lists the snapshots saved by the stages that rewrite the merged
+// snapshot, in plan order. The finalize exporter saves two: the contract
+// balances split, then the remaining contract holdings removed.
+func savedStages(plan *util.Plan) []savedStage {
+	var stages []savedStage
+	for _, step := range plan.Steps {
+		e := step.Exporter
+		switch e.Kind() {
+		case util.KindMerge:
+			stages = append(stages, savedStage{stage: e.Name(), exporter: e, file: "after-protocols"})
+		case util.KindResolver:
+			stages = append(stages, savedStage{stage: e.Name(), exporter: e, file: fmt.Sprintf("after-%s", e.Name())})
+		case util.KindFinalize:
+			stages = append(stages, savedStage{stage: e.Name(), exporter: e, file: "before-remove-contracts"},
+				savedStage{stage: "remove-contracts", exporter: e, file: "final", removesContracts: true})
+		}
+	}
+	return stages
+}
+
+// reconcile follows the total of every denom through the snapshots saved by
+// the plan. Every change of the totals paid out to users is attributed to the
+// stage that made it, and checked in audit:
+//   - after the merge, uluna and uusd add up to their supply
+//   - converting a derivative to LUNA creates what its hub holds
+//   - no other stage creates or destroys value
+func reconcile(app *terra.TerraApp, plan *util.Plan, audit *util.Audit) ([]ReconciledStage, error) {
+	ctx := util.PrepCtx(app)
+
+	var reconciled []ReconciledStage
+	var prev *util.StageTotals
+	for _, s := range savedStages(plan) {
+		totals, ok := util.RecordedTotals(s.file)
+		if !ok {
+			return nil, fmt.Errorf("no totals recorded for %s", s.file)
+		}
+		r := ReconciledStage{Stage: s.stage, Saved: s.file, Totals: totals}
+
+		if prev == nil {
+			for _, denom := range []string{util.DenomLUNA, util.DenomUST} {
+				supply, err := util.GetNativeSupply(ctx, app.BankKeeper, denom)
+				if err != nil {
+					return nil, err
+				}
+				// the hubs still hold what their derivatives are converted to later
+				held := totals.Eligible(denom).Add(orZero(totals.Hubs[denom]))
+				inv := util.Invariant{Name: fmt.Sprintf("%s adds up to its supply", denom), Severity: util.SeverityError, Tolerance: roundingTolerance(totals.Balances[denom])}
+				audit.Check(inv, supply, held)
+			}
+		} else {
+			changes, err := reconcileStage(app, s, *prev, totals, audit)
+			if err != nil {
+				return nil, err
+			}
+			r.Changes = changes
+		}
+		reconciled = append(reconciled, r)
+		prev = &totals
+	}
+	return reconciled, nil
+}
+
+func reconcileStage(app *terra.TerraApp, s savedStage, prev, cur util.StageTotals, audit *util.Audit) ([]DenomChange, error) {
+	converted := make(map[string]bool)
+	var lunaHubs []string
+	for _, r := range s.exporter.Outputs() {
+		from, to, ok := util.ConversionOf(r)
+		if !ok || s.removesContracts {
+			continue
+		}
+		converted[from], converted[to] = true, true
+		if hub, ok := util.HubOf(from); ok && to == util.DenomLUNA {
+			lunaHubs = appendMissing(lunaHubs, hub)
+		}
+	}
+
+	var changes []DenomChange
+	for _, denom := range denomsOf(prev.Totals, cur.Totals) {
+		c := DenomChange{Denom: denom, Before: prev.Eligible(denom), After: cur.Eligible(denom)}
+		c.Delta = c.After.Sub(c.Before)
+		switch {
+		case c.Delta.IsZero():
+			continue
+		case converted[denom]:
+			c.Explanation = ChangeConverted
+		case s.removesContracts && c.Delta.IsNegative():
+			c.Explanation = ChangeRemovedContracts
+		default:
+			c.Explanation = ChangeDestroyed
+			if c.Delta.IsPositive() {
+				c.Explanation = ChangeCreated
+			}
+			inv := util.Invariant{Name: fmt.Sprintf("%s conserved by %s", denom, s.file), Severity: util.SeverityWarn, Tolerance: conservationTolerance}
+			audit.Check(inv, c.Before, c.After)
+		}
+		changes = append(changes, c)
+	}
+
+	if len(lunaHubs) > 0 {
+		ctx := util.PrepCtx(app)
+		backing := sdk.ZeroInt()
+		for _, hub := range lunaHubs {
+			held, err := util.GetLunaBacking(ctx, app, hub)
+			if err != nil {
+				return nil, err
+			}
+			backing = backing.Add(held)
+		}
+		created := cur.Eligible(util.DenomLUNA).Sub(prev.Eligible(util.DenomLUNA))
+		inv := util.Invariant{Name: fmt.Sprintf("uluna created by %s is held by its hubs", s.stage), Severity: util.SeverityError, Tolerance: roundingTolerance(cur.Balances[util.DenomLUNA])}
+		audit.Check(inv, backing, created)
+	}
+	return changes, nil
+}
+
+func appendMissing(list []string, ss ...string) []string {
+outer:
+	for _, s := range ss {
+		for _, l := range list {
+			if l == s {
+				continue outer
+			}
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// writeReconciliation saves the reconciled stages as reconciliation.json in folder.
+func writeReconciliation(folder string, reconciled []ReconciledStage) error {
+	for _, r := range reconciled {
+		sort.Slice(r.Changes, func(i, j int) bool { return r.Changes[i].Denom < r.Changes[j].Denom })
+	}
+	out, err := json.MarshalIndent(reconciled, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(folder, "reconciliation.json"), out, 0666)
+}
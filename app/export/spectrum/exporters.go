@@ -0,0 +1,9 @@
+package spectrum
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewCompounderExporter("spectrum", ExportSpecVaultLPs, nil))
+}
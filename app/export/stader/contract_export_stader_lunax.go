@@ -95,7 +95,7 @@ func ResolveToLuna(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMa
 
 	er, err := GetLunaXExchangeRate(ctx, qs)
 	if err != nil {
-		return fmt.Errorf("error fetching LunaX <> Luna ER", err)
+		return fmt.Errorf("error fetching LunaX <> Luna ER: %v", err)
 	}
 
 	for _, sbs := range snapshot {
@@ -142,7 +142,10 @@ func GetLunaXUndelegations(ctx context.Context, q wasmtypes.QueryServer, contrac
 	return undelegationRequests, nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+// auditLUNA is a warning until staked LUNA is queried as well
+var auditLUNA = util.NewInvariant("uluna held by LunaX", util.SeverityWarn, 1000000)
+
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	app.Logger().Info("Audit -- LunaX")
 	ctx := util.PrepCtx(app)
 
@@ -152,9 +155,7 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 	}
 
 	// TODO: Need to also query staked Luna.
-	if err := util.AlmostEqual(util.DenomLUNA, lunaBalance, snapshot.SumOfDenom(util.DenomLUNA), sdk.NewInt(1000000)); err != nil {
-		return err
-	}
+	audit.Check(auditLUNA, lunaBalance, snapshot.SumOfDenom(util.DenomLUNA))
 
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	terra "github.com/terra-money/core/app"
 	wasmtypes "github.com/terra-money/core/x/wasm/types"
 
+	"github.com/terra-money/core/app/export/storage"
 	"github.com/terra-money/core/app/export/util"
 )
 
@@ -26,21 +27,26 @@ func ExportPools(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanceAg
 
 	// Pull users from user_registry map.
 	// pub const USER_REGISTRY: Map<(&Addr, U64Key), UserPoolInfo> = Map::new("user_registry");
-	prefix := util.GeneratePrefix("user_registry")
-	delegatorAddr, err := sdk.AccAddressFromBech32(Delegator)
+	userRegistry := storage.NewMap("user_registry", storage.KeyAddr, storage.KeyU64)
+	store, err := storage.NewContractStore(ctx, app.WasmKeeper, Delegator)
 	if err != nil {
 		return nil, err
 	}
 
 	users := []string{}
-	app.WasmKeeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(ctx), delegatorAddr, prefix, func(key, value []byte) bool {
-		// Filter out characters from start and end of the key.
-		correctedAddress := string(key)[2:46]
-		if !contains(users, correctedAddress) {
-			users = append(users, correctedAddress)
+	err = userRegistry.Range(store, func(keys storage.Keys, _ storage.Value) error {
+		address, err := keys[0].Addr()
+		if err != nil {
+			return err
 		}
-		return false
+		if !contains(users, address) {
+			users = append(users, address)
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	snapshot := make(util.SnapshotBalanceAggregateMap)
 	for _, address := range users {
@@ -0,0 +1,19 @@
+package stader
+
+import (
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterHub(util.DenomLUNAX, LunaXState)
+	util.RegisterExporter(util.NewSBAExporter("stader", ExportLunaX, Audit).WithVersion(2))
+	util.RegisterExporter(util.NewSBAExporter("stader-pools", ExportPools, nil).WithVersion(2))
+	util.RegisterExporter(util.NewSBAExporter("stader-stake-plus", ExportStakePlus, nil))
+	util.RegisterExporter(util.NewSBAExporter("stader-vaults", ExportVaults, nil))
+	util.RegisterExporter(util.NewResolverExporter("stader-luna", func(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, _ util.Blacklist) error {
+		return ResolveToLuna(app, snapshot)
+	}).
+		Consumes(util.DenomConversion(util.DenomCLUNA, util.DenomLUNA)).
+		Produces(util.DenomConversion(util.DenomLUNAX, util.DenomLUNA)))
+}
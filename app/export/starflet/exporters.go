@@ -0,0 +1,9 @@
+package starflet
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("starflet", ExportArbitrageAUST, nil).WithVersion(2))
+}
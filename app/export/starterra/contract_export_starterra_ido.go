@@ -92,7 +92,12 @@ func ExportIDO(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanceAggr
 	return snapshot, nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+var (
+	auditUST  = util.NewInvariant("uusd in ido", util.SeverityError, 10000)
+	auditAUST = util.NewInvariant("aUST in ido", util.SeverityError, 10000)
+)
+
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
 
@@ -101,20 +106,14 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 		return err
 	}
 
-	err = util.AlmostEqual("starterra ido: ust", snapshot.SumOfDenom(util.DenomUST), ustBalance, sdk.NewInt(10000))
-	if err != nil {
-		return err
-	}
+	audit.Check(auditUST, ustBalance, snapshot.SumOfDenom(util.DenomUST))
 
 	aUstBalance, err := util.GetCW20Balance(ctx, q, util.AUST, IDO)
 	if err != nil {
 		return err
 	}
 
-	err = util.AlmostEqual("starterra ido: aust", snapshot.SumOfDenom(util.DenomAUST), aUstBalance, sdk.NewInt(10000))
-	if err != nil {
-		return err
-	}
+	audit.Check(auditAUST, aUstBalance, snapshot.SumOfDenom(util.DenomAUST))
 
 	return nil
 }
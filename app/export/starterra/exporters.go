@@ -0,0 +1,9 @@
+package starterra
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("starterra", ExportIDO, Audit))
+}
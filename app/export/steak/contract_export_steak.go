@@ -109,10 +109,7 @@ func ResolveSteakLuna(app *app.TerraApp, snapshot util.SnapshotBalanceAggregateM
 	for _, balances := range snapshot {
 		for i, b := range balances {
 			if b.Denom == util.DenomSTEAK {
-				balances[i] = util.SnapshotBalance{
-					Denom:   util.DenomLUNA,
-					Balance: hubState.ExchangeRate.MulInt(b.Balance).TruncateInt(),
-				}
+				balances[i] = b.Convert(util.DenomLUNA, hubState.ExchangeRate, AddressSteakHub)
 			}
 		}
 	}
@@ -0,0 +1,16 @@
+package steak
+
+import (
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterHub(util.DenomSTEAK, AddressSteakHub)
+	util.RegisterExporter(util.NewSBAExporter("steak", ExportSteak, nil).WithVersion(2))
+	util.RegisterExporter(util.NewResolverExporter("steak-luna", func(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, _ util.Blacklist) error {
+		return ResolveSteakLuna(app, snapshot)
+	}).
+		Consumes(util.DenomConversion(util.DenomLUNAX, util.DenomLUNA)).
+		Produces(util.DenomConversion(util.DenomSTEAK, util.DenomLUNA)))
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// CW20Layout is how a cw20 contract stores the balances of its holders.
+type CW20Layout string
+
+const (
+	// CW20AddrMap is the cw-storage-plus Map<&Addr, Uint128> of cw20-base 0.8 and later
+	CW20AddrMap CW20Layout = "addr-map"
+	// CW20SnapshotMap is a SnapshotMap<&Addr, Uint128>, with balance__checkpoints
+	// and balance__changelog next to the current balances
+	CW20SnapshotMap CW20Layout = "snapshot-map"
+	// CW20CanonicalBucket is the Bucket<CanonicalAddr, Uint128> of cw20-base 0.2
+	// and the tokens forked from it, such as aUST
+	CW20CanonicalBucket CW20Layout = "canonical-bucket"
+)
+
+var (
+	cw20Balance     = NewMap("balance", KeyAddr)
+	cw20Checkpoints = NewMap("balance__checkpoints", KeyU64)
+	cw20Changelog   = NewMap("balance__changelog", KeyAddr, KeyU64)
+	cw20Canonical   = NewBucket(KeyCanonical, "balance")
+)
+
+// UnparsableEntry is a raw balance entry that could not be decoded.
+type UnparsableEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+// CW20Balances are the balances read from the state of a cw20 contract.
+type CW20Balances struct {
+	Layout     CW20Layout
+	Balances   map[string]sdk.Int
+	Unparsable []UnparsableEntry
+}
+
+// Total sums the balances.
+func (b *CW20Balances) Total() sdk.Int {
+	total := sdk.ZeroInt()
+	for _, balance := range b.Balances {
+		total = total.Add(balance)
+	}
+	return total
+}
+
+// DetectCW20Layout returns the layout of the balances in s, or false if s holds none.
+func DetectCW20Layout(s Store) (CW20Layout, bool) {
+	if hasEntries(s, cw20Checkpoints.Prefix()) || hasEntries(s, cw20Changelog.Prefix()) {
+		return CW20SnapshotMap, true
+	}
+	var first []byte
+	s.IterateWithPrefix(cw20Balance.Prefix(), func(key, _ []byte) bool {
+		first = key
+		return true
+	})
+	if first != nil {
+		if _, err := (KeyPart{Kind: KeyAddr, Raw: first}).Addr(); err == nil {
+			return CW20AddrMap, true
+		}
+		return CW20CanonicalBucket, true
+	}
+	return "", false
+}
+
+func hasEntries(s Store, prefix []byte) bool {
+	found := false
+	s.IterateWithPrefix(prefix, func(_, _ []byte) bool {
+		found = true
+		return true
+	})
+	return found
+}
+
+// ReadCW20Balances reads the balance of every holder in s, whatever its
+// layout. Entries that do not decode are returned as unparsable.
+func ReadCW20Balances(s Store) (*CW20Balances, error) {
+	layout, ok := DetectCW20Layout(s)
+	if !ok {
+		return nil, fmt.Errorf("no cw20 balances found")
+	}
+	res := &CW20Balances{Layout: layout, Balances: make(map[string]sdk.Int)}
+
+	m := cw20Balance
+	if layout == CW20CanonicalBucket {
+		m = cw20Canonical
+	}
+	err := m.Range(s, func(keys Keys, value Value) error {
+		holder, err := keys[0].Addr()
+		var balance sdk.Int
+		if err == nil {
+			balance, err = value.Int()
+		}
+		if err != nil {
+			res.Unparsable = append(res.Unparsable, UnparsableEntry{
+				Key:   hex.EncodeToString(keys[0].Raw),
+				Value: string(value),
+				Error: err.Error(),
+			})
+			return nil
+		}
+		res.Balances[holder] = balance
+		return nil
+	})
+	return res, err
+}
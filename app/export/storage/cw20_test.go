@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestReadCW20Balances(t *testing.T) {
+	alice := sdk.AccAddress(bytes.Repeat([]byte{1}, 20))
+	bob := sdk.AccAddress(bytes.Repeat([]byte{2}, 20))
+
+	stores := map[CW20Layout]memStore{
+		CW20AddrMap: {
+			string(cw20Balance.Key([]byte(alice.String()))): []byte(`"10"`),
+			string(cw20Balance.Key([]byte(bob.String()))):   []byte(`"5"`),
+		},
+		CW20SnapshotMap: {
+			string(cw20Balance.Key([]byte(alice.String()))):                   []byte(`"10"`),
+			string(cw20Balance.Key([]byte(bob.String()))):                     []byte(`"5"`),
+			string(cw20Changelog.Key([]byte(alice.String()), EncodeU64(100))): []byte(`{"old":null}`),
+		},
+		CW20CanonicalBucket: {
+			string(cw20Canonical.Key(alice)): []byte(`"10"`),
+			string(cw20Canonical.Key(bob)):   []byte(`"5"`),
+		},
+	}
+	for layout, store := range stores {
+		balances, err := ReadCW20Balances(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balances.Layout != layout {
+			t.Fatalf("expected layout %s, got %s", layout, balances.Layout)
+		}
+		if !balances.Balances[alice.String()].Equal(sdk.NewInt(10)) || !balances.Total().Equal(sdk.NewInt(15)) || len(balances.Unparsable) != 0 {
+			t.Fatalf("%s: unexpected balances %+v", layout, balances)
+		}
+	}
+
+	store := stores[CW20AddrMap]
+	store[string(cw20Balance.Key([]byte("not an address")))] = []byte(`"1"`)
+	store[string(cw20Balance.Key([]byte(alice.String())))] = []byte(`{"amount":"10"}`)
+	balances, err := ReadCW20Balances(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances.Unparsable) != 2 || len(balances.Balances) != 1 {
+		t.Fatalf("expected 2 unparsable entries, got %+v", balances)
+	}
+
+	if _, err := ReadCW20Balances(memStore{}); err == nil {
+		t.Fatal("expected a store without balances to fail")
+	}
+}
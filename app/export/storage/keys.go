@@ -0,0 +1,104 @@
+// Package storage decodes raw contract state written by cw-storage-plus and
+// cosmwasm-storage.
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// KeyKind is how an element of a key is encoded.
+type KeyKind int
+
+const (
+	// KeyAddr is an Addr, stored as its bech32 text
+	KeyAddr KeyKind = iota
+	// KeyCanonical is a CanonicalAddr, stored as raw address bytes
+	KeyCanonical
+	// KeyBytes is a &[u8] or Vec<u8>
+	KeyBytes
+	// KeyString is a &str or String
+	KeyString
+	// KeyU64 is a u64 or U64Key, stored big endian
+	KeyU64
+)
+
+// KeyPart is an element of a key read from storage.
+type KeyPart struct {
+	Kind KeyKind
+	Raw  []byte
+}
+
+// Addr returns the bech32 address of a KeyAddr or KeyCanonical part.
+func (k KeyPart) Addr() (string, error) {
+	switch k.Kind {
+	case KeyAddr:
+		if _, err := sdk.AccAddressFromBech32(string(k.Raw)); err != nil {
+			return "", err
+		}
+		return string(k.Raw), nil
+	case KeyCanonical:
+		if err := sdk.VerifyAddressFormat(k.Raw); err != nil {
+			return "", err
+		}
+		return sdk.AccAddress(k.Raw).String(), nil
+	default:
+		return "", fmt.Errorf("key of kind %d is not an address", k.Kind)
+	}
+}
+
+// U64 returns the value of a KeyU64 part.
+func (k KeyPart) U64() (uint64, error) {
+	if k.Kind != KeyU64 || len(k.Raw) != 8 {
+		return 0, fmt.Errorf("invalid u64 key %x", k.Raw)
+	}
+	return binary.BigEndian.Uint64(k.Raw), nil
+}
+
+func (k KeyPart) String() string {
+	return string(k.Raw)
+}
+
+// Keys are the elements of a composite key, in order.
+type Keys []KeyPart
+
+// EncodeU64 encodes n as a KeyU64 element.
+func EncodeU64(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
+
+// lengthPrefixed prefixes each part with its length as a 2 byte big endian
+// integer, as namespaces and all but the last element of a key are stored.
+func lengthPrefixed(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, byte(len(p)>>8), byte(len(p)))
+		out = append(out, p...)
+	}
+	return out
+}
+
+// splitKey decodes a key made of len(kinds) elements.
+func splitKey(raw []byte, kinds []KeyKind) (Keys, error) {
+	keys := make(Keys, 0, len(kinds))
+	for i, kind := range kinds {
+		if i == len(kinds)-1 {
+			keys = append(keys, KeyPart{Kind: kind, Raw: raw})
+			break
+		}
+		if len(raw) < 2 {
+			return nil, fmt.Errorf("key truncated at element %d", i)
+		}
+		n := int(binary.BigEndian.Uint16(raw))
+		if len(raw) < 2+n {
+			return nil, fmt.Errorf("key element %d longer than key", i)
+		}
+		keys = append(keys, KeyPart{Kind: kind, Raw: raw[2 : 2+n]})
+		raw = raw[2+n:]
+	}
+	return keys, nil
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	wasmkeeper "github.com/terra-money/core/x/wasm/keeper"
+)
+
+// Store iterates over the raw state of one contract.
+type Store interface {
+	IterateWithPrefix(prefix []byte, cb func(key, value []byte) bool)
+}
+
+type contractStore struct {
+	ctx      sdk.Context
+	keeper   wasmkeeper.Keeper
+	contract sdk.AccAddress
+}
+
+// NewContractStore reads the state of contract through keeper.
+func NewContractStore(ctx context.Context, keeper wasmkeeper.Keeper, contract string) (Store, error) {
+	addr, err := sdk.AccAddressFromBech32(contract)
+	if err != nil {
+		return nil, err
+	}
+	return contractStore{ctx: sdk.UnwrapSDKContext(ctx), keeper: keeper, contract: addr}, nil
+}
+
+func (s contractStore) IterateWithPrefix(prefix []byte, cb func(key, value []byte) bool) {
+	s.keeper.IterateContractStateWithPrefix(s.ctx, s.contract, prefix, cb)
+}
+
+// get returns the value stored at key, or nil if there is none.
+func get(s Store, key []byte) Value {
+	var value Value
+	s.IterateWithPrefix(key, func(k, v []byte) bool {
+		if len(k) == 0 {
+			value = v
+		}
+		return true
+	})
+	return value
+}
+
+// Value is a raw stored value. cw-storage-plus and cosmwasm-storage store JSON.
+type Value []byte
+
+// JSON decodes the value into out.
+func (v Value) JSON(out interface{}) error {
+	return json.Unmarshal(v, out)
+}
+
+// Int decodes a Uint128, stored as a quoted integer.
+func (v Value) Int() (sdk.Int, error) {
+	raw := bytes.Trim(v, `"`)
+	i, ok := sdk.NewIntFromString(string(raw))
+	if !ok {
+		return sdk.Int{}, fmt.Errorf("invalid integer %q", string(v))
+	}
+	return i, nil
+}
+
+// Item is a cw-storage-plus Item, stored under its namespace.
+type Item struct {
+	key []byte
+}
+
+func NewItem(namespace string) Item {
+	return Item{key: []byte(namespace)}
+}
+
+// Load decodes the item into out and reports whether it exists.
+func (i Item) Load(s Store, out interface{}) (bool, error) {
+	return load(s, i.key, out)
+}
+
+// Singleton is a cosmwasm-storage Singleton, stored under its length
+// prefixed namespace.
+type Singleton struct {
+	key []byte
+}
+
+func NewSingleton(namespace string) Singleton {
+	return Singleton{key: lengthPrefixed([]byte(namespace))}
+}
+
+// Load decodes the singleton into out and reports whether it exists.
+func (i Singleton) Load(s Store, out interface{}) (bool, error) {
+	return load(s, i.key, out)
+}
+
+func load(s Store, key []byte, out interface{}) (bool, error) {
+	value := get(s, key)
+	if value == nil {
+		return false, nil
+	}
+	return true, value.JSON(out)
+}
+
+// Map is a cw-storage-plus Map. Its keys are made of one element per kind;
+// every element but the last is length prefixed.
+type Map struct {
+	prefix []byte
+	kinds  []KeyKind
+}
+
+// NewMap describes a map stored under namespace with keys of the given kinds.
+func NewMap(namespace string, kinds ...KeyKind) Map {
+	return Map{prefix: lengthPrefixed([]byte(namespace)), kinds: kinds}
+}
+
+// NewBucket describes a cosmwasm-storage Bucket under nested namespaces. It is
+// stored like a Map with a single key element.
+func NewBucket(kind KeyKind, namespaces ...string) Map {
+	var ns [][]byte
+	for _, n := range namespaces {
+		ns = append(ns, []byte(n))
+	}
+	return Map{prefix: lengthPrefixed(ns...), kinds: []KeyKind{kind}}
+}
+
+// Prefix returns the raw prefix of every entry of the map.
+func (m Map) Prefix() []byte {
+	return m.prefix
+}
+
+// Sub fixes the first elements of the key, returning the map of the remaining ones.
+func (m Map) Sub(parts ...[]byte) Map {
+	if len(parts) >= len(m.kinds) {
+		panic(fmt.Errorf("sub map of %d elements of a %d element key", len(parts), len(m.kinds)))
+	}
+	return Map{prefix: append(append([]byte{}, m.prefix...), lengthPrefixed(parts...)...), kinds: m.kinds[len(parts):]}
+}
+
+// Key encodes a full key of the map.
+func (m Map) Key(parts ...[]byte) []byte {
+	if len(parts) != len(m.kinds) {
+		panic(fmt.Errorf("key of %d elements for a %d element key", len(parts), len(m.kinds)))
+	}
+	last := len(parts) - 1
+	key := append(append([]byte{}, m.prefix...), lengthPrefixed(parts[:last]...)...)
+	return append(key, parts[last]...)
+}
+
+// Load decodes the entry at the key made of parts into out and reports whether it exists.
+func (m Map) Load(s Store, out interface{}, parts ...[]byte) (bool, error) {
+	return load(s, m.Key(parts...), out)
+}
+
+// Range calls fn with every entry of the map in key order, stopping at the
+// first error. Keys that do not decode are returned as an error.
+func (m Map) Range(s Store, fn func(keys Keys, value Value) error) error {
+	var err error
+	s.IterateWithPrefix(m.prefix, func(key, value []byte) bool {
+		var keys Keys
+		if keys, err = splitKey(key, m.kinds); err != nil {
+			err = fmt.Errorf("key %x: %v", key, err)
+			return true
+		}
+		err = fn(keys, value)
+		return err != nil
+	})
+	return err
+}
+
+// MultiIndex is an index of an IndexedMap on non-unique values. Each entry is
+// stored under the index key followed by the primary key of the indexed entry.
+type MultiIndex struct {
+	m Map
+}
+
+// NewMultiIndex describes an index stored under namespace, whose index key
+// elements have the given kinds.
+func NewMultiIndex(namespace string, kinds ...KeyKind) MultiIndex {
+	return MultiIndex{m: NewMap(namespace, append(append([]KeyKind{}, kinds...), KeyBytes)...)}
+}
+
+// PrimaryKeys returns the primary keys of the entries indexed under index.
+func (i MultiIndex) PrimaryKeys(s Store, index ...[]byte) ([][]byte, error) {
+	var pks [][]byte
+	err := i.m.Sub(index...).Range(s, func(keys Keys, _ Value) error {
+		pks = append(pks, keys[len(keys)-1].Raw)
+		return nil
+	})
+	return pks, err
+}
+
+// UniqueIndex is an index of an IndexedMap on unique values. Each entry holds
+// the primary key and a copy of the indexed value.
+type UniqueIndex struct {
+	m Map
+}
+
+func NewUniqueIndex(namespace string, kinds ...KeyKind) UniqueIndex {
+	return UniqueIndex{m: NewMap(namespace, kinds...)}
+}
+
+// Load decodes the value indexed under index into out and returns its primary key.
+func (i UniqueIndex) Load(s Store, out interface{}, index ...[]byte) ([]byte, bool, error) {
+	var ref struct {
+		PK    []byte          `json:"pk"`
+		Value json.RawMessage `json:"value"`
+	}
+	found, err := i.m.Load(s, &ref, index...)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	return ref.PK, true, json.Unmarshal(ref.Value, out)
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sort"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// memStore is a contract store held in memory.
+type memStore map[string][]byte
+
+func (s memStore) IterateWithPrefix(prefix []byte, cb func(key, value []byte) bool) {
+	var keys []string
+	for k := range s {
+		if bytes.HasPrefix([]byte(k), prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if cb([]byte(k)[len(prefix):], s[k]) {
+			return
+		}
+	}
+}
+
+func TestMapRange(t *testing.T) {
+	owner := sdk.AccAddress(bytes.Repeat([]byte{1}, 20))
+	other := sdk.AccAddress(bytes.Repeat([]byte{2}, 20))
+
+	// pub const POSITIONS: Map<(&Addr, U64Key), Uint128> = Map::new("positions");
+	positions := NewMap("positions", KeyAddr, KeyU64)
+	store := memStore{
+		string(positions.Key([]byte(owner.String()), EncodeU64(1))): []byte(`"10"`),
+		string(positions.Key([]byte(owner.String()), EncodeU64(2))): []byte(`"20"`),
+		string(positions.Key([]byte(other.String()), EncodeU64(1))): []byte(`"5"`),
+		"config": []byte(`{"owner":"terra1"}`),
+	}
+
+	total := sdk.ZeroInt()
+	err := positions.Sub([]byte(owner.String())).Range(store, func(keys Keys, value Value) error {
+		if _, err := keys[0].U64(); err != nil {
+			return err
+		}
+		amount, err := value.Int()
+		total = total.Add(amount)
+		return err
+	})
+	if err != nil || !total.Equal(sdk.NewInt(30)) {
+		t.Fatalf("expected the positions of owner to sum to 30, got %s, %v", total, err)
+	}
+
+	var owners []string
+	err = positions.Range(store, func(keys Keys, _ Value) error {
+		addr, err := keys[0].Addr()
+		owners = append(owners, addr)
+		return err
+	})
+	if err != nil || len(owners) != 3 || owners[0] != other.String() {
+		t.Fatalf("unexpected owners %v, %v", owners, err)
+	}
+
+	var config struct {
+		Owner string `json:"owner"`
+	}
+	if found, err := NewItem("config").Load(store, &config); !found || err != nil || config.Owner != "terra1" {
+		t.Fatalf("unexpected config %+v, %v", config, err)
+	}
+	if found, _ := NewSingleton("config").Load(store, &config); found {
+		t.Fatal("expected a singleton to be length prefixed")
+	}
+
+	// a bucket of canonical addresses, with a multi index by owner
+	balances := NewBucket(KeyCanonical, "balance")
+	store[string(balances.Key(owner))] = []byte(`"7"`)
+	byOwner := NewMultiIndex("tokens__owner", KeyAddr)
+	store[string(byOwner.m.Key([]byte(owner.String()), []byte("token1")))] = []byte(`7`)
+	err = balances.Range(store, func(keys Keys, _ Value) error {
+		addr, err := keys[0].Addr()
+		if addr != owner.String() {
+			t.Fatalf("unexpected canonical address %s", addr)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pks, err := byOwner.PrimaryKeys(store, []byte(owner.String()))
+	if err != nil || len(pks) != 1 || string(pks[0]) != "token1" {
+		t.Fatalf("unexpected primary keys %q, %v", pks, err)
+	}
+
+	byName := NewUniqueIndex("tokens__name", KeyString)
+	store[string(byName.m.Key([]byte("luna")))] = []byte(`{"pk":"` + base64.StdEncoding.EncodeToString([]byte("token1")) + `","value":{"owner":"terra2"}}`)
+	pk, found, err := byName.Load(store, &config, []byte("luna"))
+	if !found || err != nil || string(pk) != "token1" || config.Owner != "terra2" {
+		t.Fatalf("unexpected unique index entry %q %+v, %v", pk, config, err)
+	}
+}
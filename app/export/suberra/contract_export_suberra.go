@@ -6,6 +6,7 @@ import (
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/storage"
 	util "github.com/terra-money/core/app/export/util"
 	wasmKeeper "github.com/terra-money/core/x/wasm/keeper"
 	wasmtypes "github.com/terra-money/core/x/wasm/types"
@@ -23,7 +24,10 @@ func ExportSuberra(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalance
 	qs := util.PrepWasmQueryServer(app)
 
 	// 1. get all suberra subwallets
-	subwallets := forceIterateSubwallets(ctx, app.WasmKeeper)
+	subwallets, err := forceIterateSubwallets(ctx, app.WasmKeeper)
+	if err != nil {
+		return nil, err
+	}
 
 	// 2. map subwallets' aUST balances
 	subwalletBalances := make(map[string]sdk.Int)
@@ -44,21 +48,21 @@ func ExportSuberra(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalance
 	return ownerBalances, nil
 }
 
-func forceIterateSubwallets(ctx context.Context, keeper wasmKeeper.Keeper) []string {
+func forceIterateSubwallets(ctx context.Context, keeper wasmKeeper.Keeper) ([]string, error) {
 	var subwallets []string
-
-	prefix := util.GeneratePrefix(suberraSubwalletKey)
-	addr, _ := sdk.AccAddressFromBech32(suberraSubwalletFactory)
-
-	var address sdk.AccAddress
-
-	keeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(ctx), addr, prefix, func(key, value []byte) bool {
-		util.MustUnmarshalTMJSON(value, &address)
+	store, err := storage.NewContractStore(ctx, keeper, suberraSubwalletFactory)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.NewMap(suberraSubwalletKey, storage.KeyBytes).Range(store, func(_ storage.Keys, value storage.Value) error {
+		var address sdk.AccAddress
+		if err := value.JSON(&address); err != nil {
+			return err
+		}
 		subwallets = append(subwallets, address.String())
-		return false
+		return nil
 	})
-
-	return subwallets
+	return subwallets, err
 }
 
 func iterateSubwalletsAndGetAUstBalance(ctx context.Context, q wasmtypes.QueryServer, aUST string, subwallets []string, dst map[string]sdk.Int) error {
@@ -97,7 +101,7 @@ func mapSubwalletToAdmin(ctx context.Context, q wasmtypes.QueryServer, subwallet
 	return nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	if len(snapshot) < 2 {
 		return fmt.Errorf("should have more than one sub account")
 	}
@@ -117,7 +121,7 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 		if err != nil {
 			return err
 		}
-		util.AlmostEqual(fmt.Sprintf("suberra: %s", owner), bal, balance, sdk.NewInt(1000))
+		audit.Check(util.NewInvariant(fmt.Sprintf("aUST of %s in sub wallet", owner), util.SeverityWarn, 1000), bal, balance)
 	}
 	return nil
 }
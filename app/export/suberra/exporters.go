@@ -0,0 +1,9 @@
+package suberra
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("suberra", ExportSuberra, Audit).WithVersion(2))
+}
@@ -1,12 +1,13 @@
 package terrafloki
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/amm"
 	"github.com/terra-money/core/app/export/util"
+	wasmkeeper "github.com/terra-money/core/x/wasm/keeper"
 	wasmtypes "github.com/terra-money/core/x/wasm/types"
 )
 
@@ -26,118 +27,27 @@ var (
 )
 
 // ExportTerraFloki floki pairs aren't on dexes
-func ExportTerraFloki(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanceAggregateMap, error) {
-
-	keeper := app.WasmKeeper
-
-	ctx := util.PrepCtx(app)
-	qs := util.PrepWasmQueryServer(app)
-
-	var finalBalance = make(util.SnapshotBalanceAggregateMap)
-
-	// LLP staking
-	prefix := util.GeneratePrefix("reward")
-	staking, _ := sdk.AccAddressFromBech32(FlokiLLPStaking)
-	llpHolderMap := make(map[string]sdk.Int) // lp balance
-	keeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(ctx), staking, prefix, func(key, value []byte) bool {
-		var reward struct {
-			Amount              sdk.Int `json:"bond_amount"`
-			StakingTokenVersion int     `json:"staking_token_version"`
-		}
-		if err := json.Unmarshal(value, &reward); err != nil {
-			panic(fmt.Errorf("error iterating thorugh reward keyspace: %v", err))
-		}
-		holderAddr := sdk.AccAddress(key)
-		// Handle staking contracts that have multiple staking tokens
-		if reward.StakingTokenVersion == 0 {
-			llpHolderMap[holderAddr.String()] = reward.Amount
-		}
-		return false
+func ExportTerraFloki(app *terra.TerraApp, bl util.Blacklist, _ map[string]map[string]map[string]sdk.Int, audit *util.Audit) (util.SnapshotBalanceAggregateMap, error) {
+	app.Logger().Info("Exporting TerraFloki")
+	return amm.Export(app, bl, nil, audit, amm.Factory{
+		Name:    "floki",
+		Pairs:   FlokiPairs,
+		Staking: []amm.StakingFunc{stakedLLP},
 	})
+}
 
-	for _, pairAddr := range FlokiPairs {
-		var pool pool
-		var pair pair
-
-		bl.RegisterAddress(util.DenomUST, pairAddr)
-
-		if err := util.ContractQuery(ctx, qs, &wasmtypes.QueryContractStoreRequest{
-			ContractAddress: pairAddr,
-			QueryMsg:        []byte("{\"pair\":{}}"),
-		}, &pair); err != nil {
-			return nil, err
+// stakedLLP resolves the LP tokens of the main FLOKI-UST pair staked in LLP staking.
+func stakedLLP(ctx context.Context, keeper wasmkeeper.Keeper, _ wasmtypes.QueryServer, pairs []amm.Pair) (amm.Vaults, error) {
+	vaults := make(amm.Vaults)
+	for _, p := range pairs {
+		if p.Address != FlokiPairs[0] {
+			continue
 		}
-
-		if err := util.ContractQuery(ctx, qs, &wasmtypes.QueryContractStoreRequest{
-			ContractAddress: pairAddr,
-			QueryMsg:        []byte("{\"pool\": {}}"),
-		}, &pool); err != nil {
+		stakers, err := amm.Stakers(ctx, keeper, FlokiLLPStaking)
+		if err != nil {
 			return nil, err
 		}
-
-		lpTokenAddress := pair.LiquidityToken
-		var info tokenInfo
-		if err := util.ContractQuery(ctx, qs, &wasmtypes.QueryContractStoreRequest{
-			ContractAddress: lpTokenAddress,
-			QueryMsg:        []byte("{\"token_info\":{}}"),
-		}, &info); err != nil {
-			panic(fmt.Errorf("failed to query token info: %v", err))
-		}
-
-		lpHolders := make(map[string]sdk.Int)
-		if err := util.GetCW20AccountsAndBalances(ctx, keeper, lpTokenAddress, lpHolders); err != nil {
-			return nil, fmt.Errorf("error iterating lp holders: %v", err)
-		}
-
-		// reset staking holding (if main FLOKI-UST pair)
-		if pairAddr == FlokiPairs[0] {
-			lpHolders[FlokiLLPStaking] = sdk.ZeroInt()
-
-			// add back LLP staking holders
-			for userAddr, amount := range llpHolderMap {
-				if lpHolder, ok := lpHolders[userAddr]; ok {
-					lpHolders[userAddr] = lpHolder.Add(amount)
-				} else {
-					lpHolders[userAddr] = amount
-				}
-			}
-		}
-
-		util.AssertCw20Supply(ctx, qs, lpTokenAddress, lpHolders)
-
-		// iterate over LP holders, calculate how much is to be refunded
-		for userAddr, lpBalance := range lpHolders {
-
-			refunds := getShareInAssets(pool, lpBalance, pool.TotalShare)
-			userBalance := make([]util.SnapshotBalance, 0)
-
-			if asset0name, ok := coalesceToBalanceDenom(pickDenomOrContractAddress(pool.Assets[0].AssetInfo)); ok {
-				if !refunds[0].IsZero() {
-					userBalance = append(userBalance, util.SnapshotBalance{
-						Denom:   asset0name,
-						Balance: refunds[0],
-					})
-				}
-			}
-
-			if asset1name, ok := coalesceToBalanceDenom(pickDenomOrContractAddress(pool.Assets[1].AssetInfo)); ok {
-				if !refunds[1].IsZero() {
-					userBalance = append(userBalance, util.SnapshotBalance{
-						Denom:   asset1name,
-						Balance: refunds[1],
-					})
-				}
-			}
-
-			// add to final balance if anything
-			if len(userBalance) != 0 {
-				for _, bal := range userBalance {
-					finalBalance.AppendOrAddBalance(userAddr, bal)
-				}
-			}
-		}
+		vaults.Add(FlokiLLPStaking, p.LpToken, stakers)
 	}
-
-	return finalBalance, nil
-
+	return vaults, nil
 }
@@ -0,0 +1,10 @@
+package terrafloki
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewAuditedExporter("floki", util.KindSBA, ExportTerraFloki).WithVersion(5))
+	util.RegisterExporter(util.NewSBAExporter("floki-refunds", ExportFlokiRefunds, nil))
+}
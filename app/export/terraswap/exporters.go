@@ -0,0 +1,9 @@
+package terraswap
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewAuditedExporter("terraswap", util.KindDEX, ExportTerraswapLiquidity).WithVersion(5))
+}
@@ -6,6 +6,7 @@ import (
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/amm"
 	"github.com/terra-money/core/app/export/util"
 	"github.com/terra-money/core/x/wasm/types"
 	wasmtypes "github.com/terra-money/core/x/wasm/types"
@@ -62,7 +63,7 @@ func getStakerUstBal(ctx context.Context, q types.QueryServer, userAddr string,
 	}
 
 	// 3. Pull total balances for each side of the liquidity pool.
-	var pool pool
+	var pool amm.Pair
 	if err := util.ContractQuery(ctx, q, &wasmtypes.QueryContractStoreRequest{
 		ContractAddress: initMsg.Pair,
 		QueryMsg:        []byte("{\"pool\":{}}"),
@@ -73,12 +74,12 @@ func getStakerUstBal(ctx context.Context, q types.QueryServer, userAddr string,
 	ustInPool := sdk.NewInt(0)
 
 	// 4. Find UST in the pair and pull it's total balance.
-	if pool.Assets[0].AssetInfo.NativeToken != nil {
-		if pool.Assets[0].AssetInfo.NativeToken.Denom == "uusd" {
+	if pool.Assets[0].Info.NativeToken != nil {
+		if pool.Assets[0].Info.NativeToken.Denom == "uusd" {
 			ustInPool = ustInPool.Add(pool.Assets[0].Amount)
 		}
 	} else {
-		if pool.Assets[1].AssetInfo.NativeToken.Denom == "uusd" {
+		if pool.Assets[1].Info.NativeToken.Denom == "uusd" {
 			ustInPool = ustInPool.Add(pool.Assets[1].Amount)
 		}
 	}
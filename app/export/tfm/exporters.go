@@ -0,0 +1,10 @@
+package tfm
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("tfm-farm", ExportTfmFarms, nil).WithVersion(2))
+	util.RegisterExporter(util.NewAuditedExporter("tfm-lp", util.KindSBA, ExportTfmLiquidity).WithVersion(5))
+}
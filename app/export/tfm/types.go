@@ -24,43 +24,6 @@ var (
 )
 
 type (
-	asset struct {
-		AssetInfo assetInfo `json:"info"`
-		Amount    sdk.Int   `json:"amount"`
-	}
-
-	pool struct {
-		Assets     [2]asset `json:"assets"`
-		TotalShare sdk.Int  `json:"total_share"`
-	}
-
-	pair struct {
-		AssetInfos     [2]assetInfo `json:"asset_infos"`
-		ContractAddr   []byte       `json:"contract_addr"`
-		LiquidityToken string       `json:"liquidity_token"`
-	}
-
-	assetInfo struct {
-		Token *struct {
-			ContractAddr string `json:"contract_addr"`
-		} `json:"token,omitempty"`
-		NativeToken *struct {
-			Denom string `json:"denom"`
-		} `json:"native_token,omitempty"`
-	}
-
-	poolMap map[string]pool
-	pairMap map[string]pair
-
-	refund struct {
-		asset0  string
-		asset1  string
-		refunds [2]sdk.Int
-	}
-
-	tokenInfo struct {
-		TotalSupply sdk.Int `json:"total_supply"`
-	}
 	stakerInfo struct {
 		Staker        string  `json:"staker"`
 		BondAmount    sdk.Int `json:"bond_amount"`
@@ -0,0 +1,62 @@
+package tokens
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/amm"
+	"github.com/terra-money/core/app/export/util"
+)
+
+// ExportTrackedTokens snapshots the holders of every tracked cw20, under the
+// token address. Terraswap-style staking contracts of the token are looked
+// through to their stakers; pools and vaults holding the token are looked
+// through by the exporters blacklisting them. Other contracts, such as
+// governance contracts, are not: their holdings are removed with all contract
+// holdings, listed as unattributed, and the final audit warns of the missing
+// supply.
+func ExportTrackedTokens(app *terra.TerraApp, bl util.Blacklist, _ map[string]map[string]map[string]sdk.Int, audit *util.Audit) (util.SnapshotBalanceAggregateMap, error) {
+	ctx := util.PrepCtx(app)
+	snapshot := make(util.SnapshotBalanceAggregateMap)
+	for _, token := range util.TrackedTokens() {
+		app.Logger().Info(fmt.Sprintf("Exporting cw20 %s", token))
+		balanceMap := make(map[string]sdk.Int)
+		if err := util.GetCW20AccountsAndBalances(ctx, app.WasmKeeper, token, balanceMap); err != nil {
+			return nil, fmt.Errorf("error during cw20 iteration: %v", err)
+		}
+		for _, contract := range stakingContracts(token, balanceMap) {
+			stakers, err := amm.Stakers(ctx, app.WasmKeeper, contract)
+			if err != nil {
+				return nil, fmt.Errorf("staking %s: %v", contract, err)
+			}
+			if c, ok := amm.Splice(balanceMap, contract, token, stakers); ok {
+				c.Check(audit)
+			}
+		}
+		snapshot.Add(balanceMap, token)
+	}
+	return snapshot, nil
+}
+
+// stakingContracts returns the holders of token that stake it, as named in
+// their init message, sorted.
+func stakingContracts(token string, holders map[string]sdk.Int) []string {
+	var contracts []string
+	for addr := range holders {
+		info, ok := util.SmartContractsAddresses[addr]
+		if !ok {
+			continue
+		}
+		var initMsg struct {
+			StakingToken string `json:"staking_token"`
+		}
+		if err := json.Unmarshal(info.InitMsg, &initMsg); err == nil && initMsg.StakingToken == token {
+			contracts = append(contracts, addr)
+		}
+	}
+	sort.Strings(contracts)
+	return contracts
+}
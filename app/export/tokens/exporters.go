@@ -0,0 +1,9 @@
+package tokens
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewAuditedExporter("cw20-tokens", util.KindSBA, ExportTrackedTokens).WithVersion(4))
+}
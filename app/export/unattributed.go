@@ -0,0 +1,143 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/anchor"
+	"github.com/terra-money/core/app/export/generic/common"
+	"github.com/terra-money/core/app/export/util"
+)
+
+// UnattributedBalance is a balance held by a contract that no exporter looked
+// through, which RemoveContractBalances drops from the snapshot.
+type UnattributedBalance struct {
+	Contract string
+	CodeID   uint64
+	Type     util.ContractType
+	// Label is the name given in the init message, as Terra contracts carry no label
+	Label string
+	Admin string
+	// InitMsgShape lists the top level keys of the init message
+	InitMsgShape string
+	Denom        string
+	Amount       sdk.Int
+	// Value is Amount in uusd, zero if the denom has no price
+	Value sdk.Dec
+}
+
+// UnattributedBalances lists the balances of tracked denoms that removing the
+// contracts of contractMap drops from snapshot, most valuable first. prices
+// are in uusd per unit of denom.
+func UnattributedBalances(snapshot util.SnapshotBalanceAggregateMap, contractMap common.ContractsMap, profile Profile, prices map[string]sdk.Dec) []UnattributedBalance {
+	var balances []UnattributedBalance
+	for _, addr := range util.RemovedContracts(contractMap, profile.WhitelistedContracts...) {
+		info := contractMap[addr]
+		label, shape := describeInitMsg(info.InitMsg)
+		for _, denom := range denomsHeld(snapshot, addr) {
+			amount := heldBy(snapshot, addr, denom)
+			if amount.IsZero() || !profile.keepsDenom(denom) {
+				continue
+			}
+			value := sdk.ZeroDec()
+			if price, ok := prices[denom]; ok {
+				value = price.MulInt(amount)
+			}
+			balances = append(balances, UnattributedBalance{
+				Contract:     addr,
+				CodeID:       info.CodeID,
+				Type:         util.ContractClasses.TypeOf(addr),
+				Label:        label,
+				Admin:        info.Admin,
+				InitMsgShape: shape,
+				Denom:        denom,
+				Amount:       amount,
+				Value:        value,
+			})
+		}
+	}
+	sort.SliceStable(balances, func(i, j int) bool {
+		bi, bj := balances[i], balances[j]
+		if !bi.Value.Equal(bj.Value) {
+			return bi.Value.GT(bj.Value)
+		}
+		if bi.Denom != bj.Denom {
+			return bi.Denom < bj.Denom
+		}
+		return bi.Amount.GT(bj.Amount)
+	})
+	return balances
+}
+
+// describeInitMsg returns the name and the sorted top level keys of an init message.
+func describeInitMsg(msg json.RawMessage) (string, string) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return "", ""
+	}
+	var name string
+	if raw, ok := fields["name"]; ok {
+		_ = json.Unmarshal(raw, &name)
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return name, strings.Join(keys, ";")
+}
+
+// contractPrices returns the uusd price of the denoms left after every
+// derivative has been converted to LUNA.
+func contractPrices(app *terra.TerraApp) (map[string]sdk.Dec, error) {
+	prices := map[string]sdk.Dec{util.DenomUST: sdk.OneDec()}
+	ctx := sdk.UnwrapSDKContext(util.PrepCtx(app))
+	rate, err := app.OracleKeeper.GetLunaExchangeRate(ctx, util.DenomUST)
+	if err != nil {
+		return nil, err
+	}
+	prices[util.DenomLUNA] = rate
+	aUstER, err := anchor.GetAUstExchangeRate(app)
+	if err != nil {
+		return nil, err
+	}
+	prices[util.DenomAUST] = aUstER
+	return prices, nil
+}
+
+// WriteUnattributedCSV writes the balances with a header line.
+func WriteUnattributedCSV(w io.Writer, balances []UnattributedBalance) error {
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	if err := cw.Write([]string{"contract", "code_id", "type", "label", "admin", "init_msg_shape", "denom", "amount", "value_uusd"}); err != nil {
+		return err
+	}
+	for _, b := range balances {
+		record := []string{
+			b.Contract,
+			strconv.FormatUint(b.CodeID, 10),
+			string(b.Type),
+			b.Label,
+			b.Admin,
+			b.InitMsgShape,
+			b.Denom,
+			b.Amount.String(),
+			b.Value.TruncateInt().String(),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/terra-money/core/app/export/generic/common"
+	"github.com/terra-money/core/app/export/util"
+)
+
+func TestUnattributedBalances(t *testing.T) {
+	contracts := common.ContractsMap{
+		"terra1token":     {Address: "terra1token", CodeID: 3, Admin: "terra1admin", InitMsg: []byte(`{"symbol":"TKN","name":"Token"}`)},
+		"terra1vault":     {Address: "terra1vault", CodeID: 7, InitMsg: []byte(`{"owner":"terra1admin"}`)},
+		"terra1whitelist": {Address: "terra1whitelist", CodeID: 9},
+	}
+	snapshot := util.SnapshotBalanceAggregateMap{
+		"terra1token":     {{Denom: util.DenomUST, Balance: sdk.NewInt(50)}},
+		"terra1vault":     {{Denom: util.DenomLUNA, Balance: sdk.NewInt(10)}, {Denom: util.DenomUST, Balance: sdk.ZeroInt()}, {Denom: "terra1tracked", Balance: sdk.NewInt(5)}},
+		"terra1whitelist": {{Denom: util.DenomLUNA, Balance: sdk.NewInt(1000)}},
+		"terra1user":      {{Denom: util.DenomLUNA, Balance: sdk.NewInt(1000)}},
+	}
+	profile := Profile{WhitelistedContracts: []string{"terra1whitelist"}, Denoms: []string{util.DenomLUNA, util.DenomUST}, Tokens: []string{"terra1tracked"}}
+	prices := map[string]sdk.Dec{util.DenomUST: sdk.OneDec(), util.DenomLUNA: sdk.NewDec(100)}
+
+	balances := UnattributedBalances(snapshot, contracts, profile, prices)
+	if len(balances) != 3 {
+		t.Fatalf("expected 3 balances, got %+v", balances)
+	}
+	if b := balances[0]; b.Contract != "terra1vault" || !b.Value.Equal(sdk.NewDec(1000)) || b.InitMsgShape != "owner" {
+		t.Fatalf("expected the vault to be the most valuable, got %+v", b)
+	}
+	if b := balances[1]; b.Label != "Token" || b.InitMsgShape != "name;symbol" || b.Admin != "terra1admin" {
+		t.Fatalf("unexpected token balance %+v", b)
+	}
+	if b := balances[2]; b.Denom != "terra1tracked" || !b.Amount.Equal(sdk.NewInt(5)) || !b.Value.IsZero() {
+		t.Fatalf("expected the unresolved tracked token to be listed, got %+v", b)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteUnattributedCSV(&buf, balances); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || lines[1] != "terra1vault,7,unknown,,,owner,uluna,10,1000" {
+		t.Fatalf("unexpected csv %q", buf.String())
+	}
+}
@@ -0,0 +1,144 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Asset is a native denom or cw20 the snapshot holds balances of.
+type Asset struct {
+	// Denom is the snapshot denom balances of the asset are recorded under
+	Denom string `json:"denom"`
+	// Contract is the cw20 address of the asset, empty for native denoms
+	Contract string `json:"contract,omitempty"`
+	// Decimals is 0 when unknown
+	Decimals int `json:"decimals"`
+	// Underlying is the denom the asset is converted to before the final
+	// snapshot, empty if it is kept as is
+	Underlying string `json:"underlying,omitempty"`
+	// Resolver is the exporter converting the asset to Underlying, empty when
+	// the conversion is left to the profile
+	Resolver string `json:"resolver,omitempty"`
+}
+
+// ID returns the contract address of a cw20 or the denom of a native asset,
+// which is how pools and vaults name it.
+func (a Asset) ID() string {
+	if a.Contract != "" {
+		return a.Contract
+	}
+	return a.Denom
+}
+
+// Resolved reports whether an exporter converts the asset before the final
+// snapshot, which must then hold none of it.
+func (a Asset) Resolved() bool {
+	return a.Resolver != ""
+}
+
+var registeredAssets = []Asset{
+	{Denom: DenomLUNA, Decimals: 6},
+	{Denom: DenomUST, Decimals: 6},
+	{Denom: DenomAUST, Contract: AddressAUST, Decimals: 6, Underlying: DenomUST},
+	{Denom: DenomBLUNA, Contract: AddressBLUNA, Decimals: 6, Underlying: DenomLUNA, Resolver: "lido-luna"},
+	{Denom: DenomSTLUNA, Contract: AddressSTLUNA, Decimals: 6, Underlying: DenomLUNA, Resolver: "lido-luna"},
+	{Denom: DenomNLUNA, Contract: AddressNLUNA, Decimals: 6, Underlying: DenomBLUNA, Resolver: "nexus-nluna"},
+	{Denom: DenomCLUNA, Contract: AddressCLUNA, Decimals: 6, Underlying: DenomLUNA, Resolver: "prism-luna"},
+	{Denom: DenomPLUNA, Contract: AddressPLUNA, Decimals: 6, Underlying: DenomCLUNA, Resolver: "prism-luna"},
+	{Denom: DenomSTEAK, Contract: AddressSTEAK, Decimals: 6, Underlying: DenomLUNA, Resolver: "steak-luna"},
+	{Denom: DenomLUNAX, Contract: AddressLUNAX, Decimals: 6, Underlying: DenomLUNA, Resolver: "stader-luna"},
+}
+
+// assetsByID indexes registeredAssets by native denom and cw20 address
+var assetsByID = make(map[string]Asset)
+
+func init() {
+	for _, a := range registeredAssets {
+		assetsByID[a.ID()] = a
+	}
+}
+
+// AssetOf returns the asset a native denom or cw20 address stands for. Tracked
+// tokens are assets recorded under their own address.
+func AssetOf(id string) (Asset, bool) {
+	if a, ok := assetsByID[id]; ok {
+		return a, true
+	}
+	if IsTrackedToken(id) {
+		return Asset{Denom: id, Contract: id}, true
+	}
+	return Asset{}, false
+}
+
+// BalanceDenom returns the snapshot denom of a native denom or cw20 address,
+// or false if the snapshot ignores it.
+func BalanceDenom(id string) (string, bool) {
+	a, ok := AssetOf(id)
+	return a.Denom, ok
+}
+
+// MapContractToDenom returns the snapshot denom of a native denom or cw20
+// address, panicking if the snapshot ignores it.
+func MapContractToDenom(id string) string {
+	denom, ok := BalanceDenom(id)
+	if !ok {
+		panic(fmt.Errorf("contract %s not mapped to denom", id))
+	}
+	return denom
+}
+
+// Assets returns the registered assets followed by the tracked tokens, sorted by denom.
+func Assets() []Asset {
+	assets := append([]Asset{}, registeredAssets...)
+	for _, token := range TrackedTokens() {
+		assets = append(assets, Asset{Denom: token, Contract: token})
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Denom < assets[j].Denom })
+	return assets
+}
+
+// RegisterPool blacklists a pool for every asset; its balances are given to
+// its LP holders instead.
+func RegisterPool(bl Blacklist, pool string) {
+	for _, a := range Assets() {
+		bl.RegisterAddress(a.Denom, pool)
+	}
+}
+
+// AssetInfo is how terraswap-like pairs name one of their assets.
+type AssetInfo struct {
+	Token *struct {
+		ContractAddr string `json:"contract_addr"`
+	} `json:"token,omitempty"`
+	NativeToken *struct {
+		Denom string `json:"denom"`
+	} `json:"native_token,omitempty"`
+}
+
+// ID returns the cw20 address or the native denom of the asset.
+func (a AssetInfo) ID() string {
+	if a.Token != nil {
+		return a.Token.ContractAddr
+	}
+	if a.NativeToken != nil {
+		return a.NativeToken.Denom
+	}
+	panic("unknown denom")
+}
+
+// BalanceDenom returns the snapshot denom of the asset, or false if the
+// snapshot ignores it.
+func (a AssetInfo) BalanceDenom() (string, bool) {
+	return BalanceDenom(a.ID())
+}
+
+// HasSnapshotAsset reports whether a pool of the given assets holds any asset
+// of the snapshot.
+func HasSnapshotAsset(infos ...AssetInfo) bool {
+	for _, info := range infos {
+		if _, ok := info.BalanceDenom(); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAssetRegistry(t *testing.T) {
+	for addr, denom := range map[string]string{
+		DenomUST:      DenomUST,
+		AddressAUST:   DenomAUST,
+		AddressNLUNA:  DenomNLUNA,
+		AddressSTEAK:  DenomSTEAK,
+		AddressLUNAX:  DenomLUNAX,
+		AddressSTLUNA: DenomSTLUNA,
+	} {
+		if got := MapContractToDenom(addr); got != denom {
+			t.Fatalf("expected %s to map to %s, got %s", addr, denom, got)
+		}
+	}
+	if _, ok := BalanceDenom("uxyz"); ok {
+		t.Fatal("expected unknown denoms to be ignored")
+	}
+
+	a, _ := AssetOf(AddressPLUNA)
+	if !a.Resolved() || a.Underlying != DenomCLUNA || a.Decimals != 6 {
+		t.Fatalf("unexpected pLUNA asset %+v", a)
+	}
+	if a, _ := AssetOf(AddressAUST); a.Resolved() {
+		t.Fatal("aUST is converted by the profile, not by a resolver")
+	}
+
+	var info AssetInfo
+	if err := json.Unmarshal([]byte(`{"token":{"contract_addr":"`+AddressBLUNA+`"}}`), &info); err != nil {
+		t.Fatal(err)
+	}
+	var ust AssetInfo
+	if err := json.Unmarshal([]byte(`{"native_token":{"denom":"uusd"}}`), &ust); err != nil {
+		t.Fatal(err)
+	}
+	if denom, ok := info.BalanceDenom(); !ok || denom != DenomBLUNA || !HasSnapshotAsset(ust) {
+		t.Fatalf("unexpected balance denom %s of %s", denom, info.ID())
+	}
+}
@@ -0,0 +1,199 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+type Severity string
+
+const (
+	SeverityInfo  Severity = "info"
+	SeverityWarn  Severity = "warn"
+	SeverityError Severity = "error"
+)
+
+// Invariant is a named audit check. It holds when the actual amount is less
+// than Tolerance away from the expected one. Only failed invariants of
+// SeverityError fail an export.
+type Invariant struct {
+	Name      string
+	Severity  Severity
+	Tolerance sdk.Int
+}
+
+func NewInvariant(name string, severity Severity, tolerance int64) Invariant {
+	return Invariant{Name: name, Severity: severity, Tolerance: sdk.NewInt(tolerance)}
+}
+
+// AuditResult is the outcome of checking one invariant.
+type AuditResult struct {
+	Stage     string   `json:"stage"`
+	Invariant string   `json:"invariant"`
+	Severity  Severity `json:"severity"`
+	Expected  sdk.Int  `json:"expected"`
+	Actual    sdk.Int  `json:"actual"`
+	Diff      sdk.Int  `json:"diff"`
+	// Percent is Diff as a percentage of Expected
+	Percent   sdk.Dec `json:"percent"`
+	Tolerance sdk.Int `json:"tolerance"`
+	Passed    bool    `json:"passed"`
+	// Error is set when the invariant could not be checked at all
+	Error string `json:"error,omitempty"`
+}
+
+// AuditReport collects the results of every audit of an export.
+type AuditReport struct {
+	mtx     sync.Mutex
+	results []AuditResult
+}
+
+func NewAuditReport() *AuditReport {
+	return &AuditReport{}
+}
+
+// For returns the audit that records the checks of stage.
+func (r *AuditReport) For(stage string) *Audit {
+	return &Audit{stage: stage, report: r}
+}
+
+// Results returns every result, by stage in the order they were checked.
+func (r *AuditReport) Results() []AuditResult {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	results := append([]AuditResult{}, r.results...)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Stage < results[j].Stage })
+	return results
+}
+
+// Failures returns the failed results of SeverityError.
+func (r *AuditReport) Failures() []AuditResult {
+	var failures []AuditResult
+	for _, res := range r.Results() {
+		if !res.Passed && res.Severity == SeverityError {
+			failures = append(failures, res)
+		}
+	}
+	return failures
+}
+
+// Err summarizes the failures of the report, or returns nil if there are none.
+func (r *AuditReport) Err() error {
+	failures := r.Failures()
+	if len(failures) == 0 {
+		return nil
+	}
+	var names []string
+	for _, f := range failures {
+		names = append(names, fmt.Sprintf("%s/%s", f.Stage, f.Invariant))
+	}
+	return fmt.Errorf("%d audits failed: %s", len(failures), strings.Join(names, ", "))
+}
+
+// Write saves the report as audit.json and audit.md in folder.
+func (r *AuditReport) Write(folder string) error {
+	results := r.Results()
+	out, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(folder, "audit.json"), out, 0666); err != nil {
+		return err
+	}
+
+	var md strings.Builder
+	md.WriteString("# Audit\n\n")
+	fmt.Fprintf(&md, "%d checks, %d failed with severity error.\n\n", len(results), len(r.Failures()))
+	md.WriteString("| Stage | Invariant | Severity | Expected | Actual | Diff | % | Tolerance | Result |\n")
+	md.WriteString("|---|---|---|---:|---:|---:|---:|---:|---|\n")
+	for _, res := range results {
+		status := "ok"
+		if res.Error != "" {
+			status = "error: " + res.Error
+		} else if !res.Passed {
+			status = "FAILED"
+		}
+		fmt.Fprintf(&md, "| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			res.Stage, res.Invariant, res.Severity, orNil(res.Expected), orNil(res.Actual), orNil(res.Diff),
+			formatPercent(res.Percent), orNil(res.Tolerance), status)
+	}
+	return os.WriteFile(filepath.Join(folder, "audit.md"), []byte(md.String()), 0666)
+}
+
+func orNil(i sdk.Int) string {
+	if i.IsNil() {
+		return "-"
+	}
+	return i.String()
+}
+
+func formatPercent(d sdk.Dec) string {
+	if d.IsNil() {
+		return "-"
+	}
+	f, err := strconv.ParseFloat(d.String(), 64)
+	if err != nil {
+		return d.String()
+	}
+	return fmt.Sprintf("%.4f", f)
+}
+
+// Audit records the invariants checked by one stage.
+type Audit struct {
+	stage  string
+	report *AuditReport
+}
+
+// Check records whether actual is within the tolerance of expected and
+// reports whether it is.
+func (a *Audit) Check(inv Invariant, expected sdk.Int, actual sdk.Int) bool {
+	res := AuditResult{
+		Stage:     a.stage,
+		Invariant: inv.Name,
+		Severity:  inv.Severity,
+		Expected:  expected,
+		Actual:    actual,
+		Tolerance: inv.Tolerance,
+	}
+	if expected.IsNil() || actual.IsNil() {
+		res.Error = "inputs nil"
+	} else {
+		res.Diff = actual.Sub(expected)
+		switch {
+		case !expected.IsZero():
+			res.Percent = res.Diff.ToDec().MulInt64(100).QuoInt(expected)
+		case res.Diff.IsZero():
+			res.Percent = sdk.ZeroDec()
+		default:
+			res.Percent = sdk.NewDec(100)
+		}
+		res.Passed = res.Diff.Abs().LT(inv.Tolerance)
+	}
+	a.record(res)
+	return res.Passed
+}
+
+// Fail records an error that kept the stage from checking its invariants. It
+// fails the export.
+func (a *Audit) Fail(err error) {
+	a.record(AuditResult{
+		Stage:     a.stage,
+		Invariant: "audit",
+		Severity:  SeverityError,
+		Error:     err.Error(),
+	})
+}
+
+func (a *Audit) record(res AuditResult) {
+	a.report.mtx.Lock()
+	defer a.report.mtx.Unlock()
+	a.report.results = append(a.report.results, res)
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestAuditFailsOnlyOnErrorSeverity(t *testing.T) {
+	report := NewAuditReport()
+	audit := report.For("vault")
+
+	if !audit.Check(NewInvariant("within tolerance", SeverityError, 10), sdk.NewInt(100), sdk.NewInt(91)) {
+		t.Fatal("expected a diff of 9 to be within a tolerance of 10")
+	}
+	if audit.Check(NewInvariant("warning", SeverityWarn, 10), sdk.NewInt(100), sdk.NewInt(110)) {
+		t.Fatal("expected a diff of 10 to fail a tolerance of 10")
+	}
+	if err := report.Err(); err != nil {
+		t.Fatalf("expected warnings not to fail the report, got %v", err)
+	}
+
+	audit.Check(NewInvariant("supply", SeverityError, 10), sdk.NewInt(200), sdk.NewInt(150))
+	report.For("finalize").Fail(fmt.Errorf("query failed"))
+	if failures := report.Failures(); len(failures) != 2 || failures[0].Stage != "finalize" || failures[1].Invariant != "supply" {
+		t.Fatalf("unexpected failures %+v", failures)
+	}
+	if report.Err() == nil {
+		t.Fatal("expected failed errors to fail the report")
+	}
+
+	dir := t.TempDir()
+	if err := report.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "audit.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []AuditResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+	supply := results[3]
+	if supply.Invariant != "supply" || !supply.Diff.Equal(sdk.NewInt(-50)) || !supply.Percent.Equal(sdk.NewDec(-25)) {
+		t.Fatalf("unexpected result %+v", supply)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit.md")); err != nil {
+		t.Fatal(err)
+	}
+}
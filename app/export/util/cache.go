@@ -0,0 +1,421 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	terra "github.com/terra-money/core/app"
+)
+
+const cacheManifestFile = "manifest.json"
+
+// CacheEntry describes one cached exporter output.
+type CacheEntry struct {
+	Exporter     string    `json:"exporter"`
+	Version      int       `json:"version"`
+	Height       int64     `json:"height"`
+	SnapshotType Snapshot  `json:"snapshot_type"`
+	InputsHash   string    `json:"inputs_hash"`
+	Key          string    `json:"key"`
+	File         string    `json:"file"`
+	ContentHash  string    `json:"content_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type CacheManifest struct {
+	Entries map[string]CacheEntry `json:"entries"`
+}
+
+// Cache stores exporter outputs under ./cache-<height>. Entries are addressed by
+// exporter name, exporter version, height, snapshot type and a hash of the
+// outputs the exporter consumed and of the inputs added with AddInput, so a
+// change to any of those re-runs the exporter.
+type Cache struct {
+	folder     string
+	height     int64
+	refresh    map[string]bool
+	refreshAll bool
+	// inputs are read by every exporter besides the outputs it consumes
+	inputs map[string]string
+
+	mtx      sync.Mutex
+	manifest CacheManifest
+}
+
+func CacheFolder(height int64) string {
+	return fmt.Sprintf("./cache-%d", height)
+}
+
+// OpenCache loads the manifest for height. Exporters named in refresh, or all
+// exporters if refreshAll is set, ignore their cached entries.
+func OpenCache(height int64, refresh []string, refreshAll bool) (*Cache, error) {
+	c := &Cache{
+		folder:     CacheFolder(height),
+		height:     height,
+		refresh:    make(map[string]bool),
+		refreshAll: refreshAll,
+		inputs:     make(map[string]string),
+		manifest:   CacheManifest{Entries: make(map[string]CacheEntry)},
+	}
+	for _, name := range refresh {
+		c.refresh[name] = true
+	}
+	if err := os.MkdirAll(c.folder, 0777); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(c.manifestPath())
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.manifest); err != nil {
+		return nil, fmt.Errorf("corrupt cache manifest %s: %v", c.manifestPath(), err)
+	}
+	if c.manifest.Entries == nil {
+		c.manifest.Entries = make(map[string]CacheEntry)
+	}
+	return c, nil
+}
+
+func (c *Cache) Folder() string {
+	return c.folder
+}
+
+func (c *Cache) manifestPath() string {
+	return filepath.Join(c.folder, cacheManifestFile)
+}
+
+// Entries returns the manifest entries sorted by exporter name.
+func (c *Cache) Entries() []CacheEntry {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	entries := make([]CacheEntry, 0, len(c.manifest.Entries))
+	for _, entry := range c.manifest.Entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Exporter < entries[j].Exporter
+	})
+	return entries
+}
+
+// Entry returns the manifest entry of an exporter.
+func (c *Cache) Entry(name string) (CacheEntry, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	entry, ok := c.manifest.Entries[name]
+	return entry, ok
+}
+
+// AddInput adds v to the inputs hash of every entry. It is for settings that
+// change exporter outputs without being an output of another stage, such as
+// the profile's extra blacklist.
+func (c *Cache) AddInput(name string, v interface{}) error {
+	if bl, ok := v.(Blacklist); ok {
+		v = canonicalBlacklist(bl)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.inputs[name] = string(data)
+	return nil
+}
+
+// Expected returns the entry an exporter would be cached under, given the
+// outputs of the stages it depends on.
+func (c *Cache) Expected(e Exporter, snapshotType Snapshot, deps map[string]ExportOutput) (CacheEntry, error) {
+	inputsHash, err := HashInputs(deps)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	if inputs := c.inputsData(); inputs != "" {
+		inputsHash = hashBytes([]byte(inputsHash + "\n" + inputs))
+	}
+	keyData := fmt.Sprintf("%s|%d|%d|%s|%s", e.Name(), e.Version(), c.height, snapshotType, inputsHash)
+	key := sha256.Sum256([]byte(keyData))
+	keyHex := hex.EncodeToString(key[:])
+	return CacheEntry{
+		Exporter:     e.Name(),
+		Version:      e.Version(),
+		Height:       c.height,
+		SnapshotType: snapshotType,
+		InputsHash:   inputsHash,
+		Key:          keyHex,
+		File:         fmt.Sprintf("%s.%s.json", e.Name(), keyHex[:12]),
+	}, nil
+}
+
+func (c *Cache) inputsData() string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	names := make([]string, 0, len(c.inputs))
+	for name := range c.inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var data strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&data, "%s=%s\n", name, c.inputs[name])
+	}
+	return data.String()
+}
+
+// Run returns the cached output of e if a valid entry exists, otherwise it runs
+// the exporter and caches the result. The exporter registers addresses on a copy
+// of bl; the recorded delta is stored with the entry and added to bl on both
+// paths, so a cache hit blacklists the same addresses as a fresh run.
+func (c *Cache) Run(app *terra.TerraApp, e Exporter, bl Blacklist, state *ExportState, deps map[string]ExportOutput) (out ExportOutput, hit bool, err error) {
+	expected, err := c.Expected(e, state.SnapshotType, deps)
+	if err != nil {
+		return ExportOutput{}, false, err
+	}
+
+	out, hit = ExportOutput{}, false
+	if !c.refreshAll && !c.refresh[e.Name()] {
+		out, hit = c.load(expected)
+	}
+	if !hit {
+		base := bl.Copy()
+		view := bl.Copy()
+		if out, err = Export(app, e, view, state); err != nil {
+			return ExportOutput{}, false, err
+		}
+		out.BlacklistDelta = view.Since(base)
+		if err := c.store(expected, out); err != nil {
+			return ExportOutput{}, false, err
+		}
+	}
+
+	bl.Register(out.BlacklistDelta)
+	return out, hit, c.summarize(e.Name(), out)
+}
+
+func (c *Cache) load(expected CacheEntry) (ExportOutput, bool) {
+	entry, ok := c.Entry(expected.Exporter)
+	if !ok || entry.Key != expected.Key {
+		return ExportOutput{}, false
+	}
+	data, err := os.ReadFile(filepath.Join(c.folder, entry.File))
+	if err != nil || hashBytes(data) != entry.ContentHash {
+		return ExportOutput{}, false
+	}
+	var out ExportOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return ExportOutput{}, false
+	}
+	return out, true
+}
+
+func (c *Cache) store(entry CacheEntry, out ExportOutput) error {
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(c.folder, entry.File), data, 0666); err != nil {
+		return err
+	}
+	entry.ContentHash = hashBytes(data)
+	entry.CreatedAt = time.Now().UTC()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.manifest.Entries[entry.Exporter] = entry
+	manifest, err := json.MarshalIndent(c.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write then rename so an interrupted run never leaves a partial manifest
+	tmp := c.manifestPath() + ".tmp"
+	if err := os.WriteFile(tmp, manifest, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.manifestPath())
+}
+
+// LoadEntry reads the output stored for an entry, checking its content hash.
+func (c *Cache) LoadEntry(entry CacheEntry) (ExportOutput, error) {
+	data, err := os.ReadFile(filepath.Join(c.folder, entry.File))
+	if err != nil {
+		return ExportOutput{}, err
+	}
+	if hashBytes(data) != entry.ContentHash {
+		return ExportOutput{}, fmt.Errorf("%s was modified after it was cached", entry.File)
+	}
+	var out ExportOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return ExportOutput{}, err
+	}
+	return out, nil
+}
+
+func (c *Cache) summarize(name string, out ExportOutput) error {
+	if out.Snapshot == nil {
+		return nil
+	}
+	return SummarizeProtocolTotals(out.Snapshot, filepath.Join(c.folder, "summary.csv"), name)
+}
+
+// HashInputs hashes the outputs an exporter consumed. Balances are sorted first
+// so the hash does not depend on map iteration order.
+func HashInputs(deps map[string]ExportOutput) (string, error) {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		out := deps[name]
+		snapshot, err := json.Marshal(canonicalBalances(out.Snapshot))
+		if err != nil {
+			return "", err
+		}
+		// nested maps marshal with sorted keys
+		lpHoldings, err := json.Marshal(out.LpHoldings)
+		if err != nil {
+			return "", err
+		}
+		blacklist, err := json.Marshal(canonicalBlacklist(out.BlacklistDelta))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", name, snapshot, lpHoldings, blacklist)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func canonicalBalances(snapshot SnapshotBalanceAggregateMap) map[string][]string {
+	canonical := make(map[string][]string, len(snapshot))
+	for addr, sbs := range snapshot {
+		balances := make([]string, len(sbs))
+		for i, sb := range sbs {
+			balances[i] = fmt.Sprintf("%s:%s", sb.Denom, sb.Balance)
+		}
+		sort.Strings(balances)
+		canonical[addr] = balances
+	}
+	return canonical
+}
+
+func canonicalBlacklist(bl Blacklist) map[string][]string {
+	canonical := make(map[string][]string, len(bl))
+	for denom, addrs := range bl {
+		sorted := append([]string{}, addrs...)
+		sort.Strings(sorted)
+		canonical[denom] = sorted
+	}
+	return canonical
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type CacheState string
+
+const (
+	CacheOK         CacheState = "ok"
+	CacheStale      CacheState = "stale"
+	CacheMissing    CacheState = "missing"
+	CacheCorrupt    CacheState = "corrupt"
+	CacheOrphan     CacheState = "orphan"
+	CacheUnverified CacheState = "unverified"
+)
+
+// CacheStatus is the verification result of one exporter's cache entry.
+type CacheStatus struct {
+	Exporter string
+	State    CacheState
+	Detail   string
+	Entry    CacheEntry
+}
+
+// Verify checks the cache entry of every protocol exporter in the plan: the
+// file must match its content hash, the exporter version must be current and
+// the inputs hash must match the cached outputs of its dependencies.
+func (c *Cache) Verify(plan *Plan) []CacheStatus {
+	var statuses []CacheStatus
+	planned := make(map[string]bool)
+	outputs := make(map[string]ExportOutput)
+	for _, step := range plan.Steps {
+		e := step.Exporter
+		planned[e.Name()] = true
+		if !e.Kind().IsProtocol() {
+			continue
+		}
+		statuses = append(statuses, c.verifyStep(plan.SnapshotType, step, outputs))
+	}
+
+	for _, entry := range c.Entries() {
+		if !planned[entry.Exporter] {
+			statuses = append(statuses, CacheStatus{
+				Exporter: entry.Exporter,
+				State:    CacheOrphan,
+				Detail:   fmt.Sprintf("no %s exporter in the plan", plan.SnapshotType),
+				Entry:    entry,
+			})
+		}
+	}
+	return statuses
+}
+
+func (c *Cache) verifyStep(snapshotType Snapshot, step PlanStep, outputs map[string]ExportOutput) CacheStatus {
+	e := step.Exporter
+	entry, ok := c.Entry(e.Name())
+	if !ok {
+		return CacheStatus{Exporter: e.Name(), State: CacheMissing}
+	}
+	status := CacheStatus{Exporter: e.Name(), Entry: entry}
+
+	out, err := c.LoadEntry(entry)
+	if err != nil {
+		status.State, status.Detail = CacheCorrupt, err.Error()
+		return status
+	}
+	outputs[e.Name()] = out
+
+	if entry.Version != e.Version() {
+		status.State, status.Detail = CacheStale, fmt.Sprintf("cached by version %d, exporter is at version %d", entry.Version, e.Version())
+		return status
+	}
+	if entry.Height != c.height || entry.SnapshotType != snapshotType {
+		status.State, status.Detail = CacheStale, fmt.Sprintf("cached for %s @ %d", entry.SnapshotType, entry.Height)
+		return status
+	}
+
+	deps := make(map[string]ExportOutput)
+	for _, dep := range step.DependsOn {
+		depOut, ok := outputs[dep]
+		if !ok {
+			status.State, status.Detail = CacheUnverified, fmt.Sprintf("dependency %s has no usable cache entry", dep)
+			return status
+		}
+		deps[dep] = depOut
+	}
+	expected, err := c.Expected(e, snapshotType, deps)
+	if err != nil {
+		status.State, status.Detail = CacheCorrupt, err.Error()
+		return status
+	}
+	if expected.InputsHash != entry.InputsHash {
+		status.State, status.Detail = CacheStale, "inputs changed since the entry was cached"
+		return status
+	}
+	status.State = CacheOK
+	return status
+}
@@ -0,0 +1,217 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	terra "github.com/terra-money/core/app"
+)
+
+func TestCacheVerifyDetectsStaleInputs(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	a := NewSBAExporter("a", func(*terra.TerraApp, Blacklist) (SnapshotBalanceAggregateMap, error) { return nil, nil }, nil)
+	b := NewExporter("b", KindSBA, func(*terra.TerraApp, Blacklist, *ExportState) (ExportOutput, error) { return ExportOutput{}, nil }).
+		Consumes(SnapshotOf("a"))
+	plan, err := BuildPlan(Snapshot(PostAttack), []Exporter{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := OpenCache(1, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(e Exporter, out ExportOutput, deps map[string]ExportOutput) {
+		entry, err := cache.Expected(e, plan.SnapshotType, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.store(entry, out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	states := func() map[string]CacheState {
+		reopened, err := OpenCache(1, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := make(map[string]CacheState)
+		for _, s := range reopened.Verify(plan) {
+			res[s.Exporter] = s.State
+		}
+		return res
+	}
+
+	aOut := ExportOutput{Snapshot: SnapshotBalanceAggregateMap{
+		"terra1": {{Denom: DenomUST, Balance: sdk.NewInt(1)}},
+	}}
+	put(a, aOut, nil)
+	put(b, ExportOutput{}, map[string]ExportOutput{"a": aOut})
+	if s := states(); s["a"] != CacheOK || s["b"] != CacheOK {
+		t.Fatalf("expected valid entries, got %v", s)
+	}
+
+	aOut.Snapshot["terra1"][0].Balance = sdk.NewInt(2)
+	put(a, aOut, nil)
+	if s := states(); s["a"] != CacheOK || s["b"] != CacheStale {
+		t.Fatalf("expected b to be stale, got %v", s)
+	}
+
+	keyed, err := OpenCache(1, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyed.AddInput("extra_blacklist", Blacklist{DenomUST: {"terra1"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range keyed.Verify(plan) {
+		if s.State != CacheStale {
+			t.Fatalf("expected entries to be stale under another blacklist, got %s %s", s.Exporter, s.State)
+		}
+	}
+
+	a.WithVersion(2)
+	if s := states(); s["a"] != CacheStale {
+		t.Fatalf("expected a to be stale after a version bump, got %v", s)
+	}
+}
+
+func TestCacheHitReplaysBlacklist(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	runs := 0
+	e := NewSBAExporter("pair", func(_ *terra.TerraApp, bl Blacklist) (SnapshotBalanceAggregateMap, error) {
+		runs++
+		bl.RegisterAddress(DenomUST, "terra1pair")
+		return SnapshotBalanceAggregateMap{
+			"terra1user": {{Denom: DenomUST, Balance: sdk.NewInt(10)}},
+			"terra1pair": {{Denom: DenomUST, Balance: sdk.NewInt(90)}},
+		}, nil
+	}, nil)
+
+	export := func() []byte {
+		cache, err := OpenCache(1, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bl := Blacklist{DenomUST: []string{}}
+		out, _, err := cache.Run(nil, e, bl, NewExportState(Snapshot(PostAttack)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot := MergeSnapshots(out.Snapshot)
+		snapshot.ApplyBlackList(bl)
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	cold := export()
+	warm := export()
+	if runs != 1 {
+		t.Fatalf("expected the warm run to hit the cache, exporter ran %d times", runs)
+	}
+	if !bytes.Equal(cold, warm) {
+		t.Fatalf("warm run differs from cold run:\n%s\n%s", cold, warm)
+	}
+}
+
+func TestCacheHitReplaysChecks(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	runs := 0
+	e := NewAuditedExporter("dex", KindSBA, func(_ *terra.TerraApp, _ Blacklist, _ map[string]map[string]map[string]sdk.Int, audit *Audit) (SnapshotBalanceAggregateMap, error) {
+		runs++
+		audit.Check(NewInvariant("vault claims", SeverityError, 1), sdk.NewInt(10), sdk.NewInt(20))
+		return nil, nil
+	})
+	for i := 0; i < 2; i++ {
+		cache, err := OpenCache(1, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, _, err := cache.Run(nil, e, Blacklist{}, NewExportState(Snapshot(PostAttack)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := NewAuditReport()
+		if err := e.Audit(nil, out, report.For("dex")); err != nil {
+			t.Fatal(err)
+		}
+		if report.Err() == nil {
+			t.Fatalf("run %d: expected the failed check to be reported", i)
+		}
+	}
+	if runs != 1 {
+		t.Fatalf("expected the second run to hit the cache, exporter ran %d times", runs)
+	}
+}
+
+func TestCacheHitKeepsCW20Reports(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	e := NewSBAExporter("token", func(app *terra.TerraApp, _ Blacklist) (SnapshotBalanceAggregateMap, error) {
+		cw20CollectorOf(app).record(CW20Report{Contract: "terra1token", Total: sdk.NewInt(1), Supply: sdk.NewInt(2)})
+		return nil, nil
+	}, nil)
+	for i := 0; i < 2; i++ {
+		cache, err := OpenCache(1, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state := NewExportState(Snapshot(PostAttack))
+		out, _, err := cache.Run(nil, e, Blacklist{}, state, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state.SetOutput(e.Name(), out)
+		if err := AuditCW20Reports(state, state.Audit.For("cw20"), cache.Folder()); err != nil {
+			t.Fatal(err)
+		}
+		if results := state.Audit.Results(); len(results) != 2 || results[0].Passed {
+			t.Fatalf("run %d: expected the token to be audited, got %+v", i, results)
+		}
+	}
+}
+
+func TestCollectCW20IsScopedToTheExporter(t *testing.T) {
+	app := &terra.TerraApp{}
+	var inner []CW20Report
+	outer, err := CollectCW20(app, func(a *terra.TerraApp) error {
+		var err error
+		inner, err = CollectCW20(app, func(b *terra.TerraApp) error {
+			cw20CollectorOf(b).record(CW20Report{Contract: "terra1inner"})
+			return nil
+		})
+		cw20CollectorOf(a).record(CW20Report{Contract: "terra1outer"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outer) != 1 || outer[0].Contract != "terra1outer" || len(inner) != 1 || inner[0].Contract != "terra1inner" {
+		t.Fatalf("expected each call to collect its own reports, got %+v and %+v", outer, inner)
+	}
+}
@@ -0,0 +1,296 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/generic/common"
+	wasmkeeper "github.com/terra-money/core/x/wasm/keeper"
+	wasmtypes "github.com/terra-money/core/x/wasm/types"
+)
+
+type ContractType string
+
+const (
+	ContractUnknown       ContractType = "unknown"
+	ContractCW20          ContractType = "cw20"
+	ContractCW3Fixed      ContractType = "cw3-fixed-multisig"
+	ContractCW3Flex       ContractType = "cw3-flex-multisig"
+	ContractCW4Group      ContractType = "cw4-group"
+	ContractCW4Stake      ContractType = "cw4-stake"
+	ContractCW1Subkeys    ContractType = "cw1-subkeys"
+	ContractVesting       ContractType = "vesting"
+	ContractTerraswapPair ContractType = "terraswap-pair"
+	ContractAstroportPair ContractType = "astroport-pair"
+	ContractStaking       ContractType = "staking"
+)
+
+const contractClassesFile = "contract-classes.json"
+
+// classifierVersion is bumped whenever classification changes, invalidating
+// saved tables
+const classifierVersion = 2
+
+// probesPerCode is the number of contracts of a code probed to classify it
+const probesPerCode = 3
+
+// ContractClasses classifies every contract of the export, set once contracts
+// have been iterated and before any exporter runs. Any exporter may read it to
+// find the contracts of a standard.
+var ContractClasses *ContractTable
+
+// ContractClass groups the contracts instantiated from one code.
+type ContractClass struct {
+	CodeID    uint64       `json:"code_id"`
+	Checksum  string       `json:"checksum"`
+	Type      ContractType `json:"type"`
+	Contracts []string     `json:"contracts"`
+}
+
+// ContractTable is the classification of every contract at a height, by code.
+type ContractTable struct {
+	Version int             `json:"version"`
+	Height  int64           `json:"height"`
+	Codes   []ContractClass `json:"codes"`
+
+	types map[string]ContractType
+}
+
+func newContractTable(height int64, codes []ContractClass) *ContractTable {
+	sort.Slice(codes, func(i, j int) bool { return codes[i].CodeID < codes[j].CodeID })
+	t := &ContractTable{Version: classifierVersion, Height: height, Codes: codes, types: make(map[string]ContractType)}
+	for _, c := range codes {
+		sort.Strings(c.Contracts)
+		for _, addr := range c.Contracts {
+			t.types[addr] = c.Type
+		}
+	}
+	return t
+}
+
+// TypeOf returns the type of contract, ContractUnknown if it is not classified.
+func (t *ContractTable) TypeOf(contract string) ContractType {
+	if t == nil {
+		return ContractUnknown
+	}
+	if typ, ok := t.types[contract]; ok {
+		return typ
+	}
+	return ContractUnknown
+}
+
+// ContractsOf returns the contracts of any of the given types, sorted.
+func (t *ContractTable) ContractsOf(types ...ContractType) []string {
+	if t == nil {
+		return nil
+	}
+	var contracts []string
+	for _, c := range t.Codes {
+		for _, typ := range types {
+			if c.Type == typ {
+				contracts = append(contracts, c.Contracts...)
+			}
+		}
+	}
+	sort.Strings(contracts)
+	return contracts
+}
+
+// covers reports whether every contract of contracts is classified.
+func (t *ContractTable) covers(contracts common.ContractsMap) bool {
+	for addr := range contracts {
+		if _, ok := t.types[addr]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Save writes the table as contract-classes.json in folder.
+func (t *ContractTable) Save(folder string) error {
+	out, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(folder, contractClassesFile), out, 0666)
+}
+
+// LoadContractTable reads the table saved in the cache folder of height by the
+// current classifier.
+func LoadContractTable(height int64) (*ContractTable, error) {
+	data, err := os.ReadFile(filepath.Join(CacheFolder(height), contractClassesFile))
+	if err != nil {
+		return nil, err
+	}
+	var t ContractTable
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	if t.Height != height {
+		return nil, fmt.Errorf("contract classes are for height %d, expected %d", t.Height, height)
+	}
+	if t.Version != classifierVersion {
+		return nil, fmt.Errorf("contract classes are from classifier version %d, expected %d", t.Version, classifierVersion)
+	}
+	return newContractTable(t.Height, t.Codes), nil
+}
+
+// ClassifyContracts groups contracts by code and classifies each code by
+// probing its contracts. The table saved in the cache folder is reused if it
+// covers every contract.
+func ClassifyContracts(app *terra.TerraApp, contracts common.ContractsMap) (*ContractTable, error) {
+	height := app.LastBlockHeight()
+	if t, err := LoadContractTable(height); err == nil && t.covers(contracts) {
+		return t, nil
+	}
+
+	ctx := PrepCtx(app)
+	probe := liveProbe{ctx: ctx, qs: PrepWasmQueryServer(app), keeper: app.WasmKeeper}
+
+	byCode := make(map[uint64]*ContractClass)
+	for addr, info := range contracts {
+		c, ok := byCode[info.CodeID]
+		if !ok {
+			code, err := app.WasmKeeper.GetCodeInfo(sdk.UnwrapSDKContext(ctx), info.CodeID)
+			if err != nil {
+				return nil, err
+			}
+			c = &ContractClass{CodeID: info.CodeID, Checksum: hex.EncodeToString(code.CodeHash)}
+			byCode[info.CodeID] = c
+		}
+		c.Contracts = append(c.Contracts, addr)
+	}
+
+	// codes uploaded more than once share the type of their checksum
+	byChecksum := make(map[string]ContractType)
+	var codes []ContractClass
+	for _, c := range byCode {
+		sort.Strings(c.Contracts)
+		typ, ok := byChecksum[c.Checksum]
+		if !ok {
+			typ = classifyCode(probe, contracts, c.Contracts)
+			byChecksum[c.Checksum] = typ
+		}
+		c.Type = typ
+		codes = append(codes, *c)
+	}
+
+	t := newContractTable(height, codes)
+	folder := CacheFolder(height)
+	_ = os.Mkdir(folder, 0777)
+	return t, t.Save(folder)
+}
+
+// classifyCode returns the type of a code, detected on its first contracts. A
+// code whose probed contracts disagree is left unknown rather than given the
+// type of one of them.
+func classifyCode(p contractProbe, contracts common.ContractsMap, addrs []string) ContractType {
+	typ := ContractUnknown
+	for i, addr := range addrs {
+		if i == probesPerCode {
+			break
+		}
+		detected := detectContractType(p, contracts[addr])
+		if i > 0 && detected != typ {
+			return ContractUnknown
+		}
+		typ = detected
+	}
+	return typ
+}
+
+// contractProbe answers the queries and storage lookups used to classify contracts.
+type contractProbe interface {
+	// Query returns the response of a smart query, or false if the contract rejects it
+	Query(contract string, msg string) (json.RawMessage, bool)
+	// HasPrefix reports whether the contract stores any key starting with prefix
+	HasPrefix(contract string, prefix []byte) bool
+}
+
+type liveProbe struct {
+	ctx    context.Context
+	qs     wasmtypes.QueryServer
+	keeper wasmkeeper.Keeper
+}
+
+func (p liveProbe) Query(contract string, msg string) (json.RawMessage, bool) {
+	var res json.RawMessage
+	err := ContractQuery(p.ctx, p.qs, &wasmtypes.QueryContractStoreRequest{
+		ContractAddress: contract,
+		QueryMsg:        []byte(msg),
+	}, &res)
+	return res, err == nil
+}
+
+func (p liveProbe) HasPrefix(contract string, prefix []byte) bool {
+	addr, err := sdk.AccAddressFromBech32(contract)
+	if err != nil {
+		return false
+	}
+	found := false
+	p.keeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(p.ctx), addr, prefix, func(_, _ []byte) bool {
+		found = true
+		return true
+	})
+	return found
+}
+
+// detectContractType probes a contract for the well-known standards. Probes
+// go from the most to the least specific, as a pair or a multisig may answer
+// the queries of simpler standards too.
+func detectContractType(p contractProbe, info wasmtypes.ContractInfo) ContractType {
+	addr := info.Address
+	if p.HasPrefix(addr, []byte("vesting_info")) {
+		if _, ok := p.Query(addr, `{"vesting_info":{}}`); ok {
+			return ContractVesting
+		}
+	}
+	if _, ok := p.Query(addr, `{"threshold":{}}`); ok {
+		if p.HasPrefix(addr, GeneratePrefix("voters")) {
+			return ContractCW3Fixed
+		}
+		return ContractCW3Flex
+	}
+	if _, ok := p.Query(addr, `{"total_weight":{}}`); ok {
+		if _, ok := p.Query(addr, `{"list_members":{}}`); ok {
+			// cw4-stake is a group whose weights are stakes
+			if _, ok := p.Query(addr, fmt.Sprintf(`{"staked":{"address":"%s"}}`, addr)); ok {
+				return ContractCW4Stake
+			}
+			return ContractCW4Group
+		}
+	}
+	if _, ok := p.Query(addr, `{"all_allowances":{}}`); ok {
+		return ContractCW1Subkeys
+	}
+	if res, ok := p.Query(addr, `{"pair":{}}`); ok {
+		if hasField(res, "pair_type") {
+			return ContractAstroportPair
+		}
+		return ContractTerraswapPair
+	}
+	if res, ok := p.Query(addr, `{"token_info":{}}`); ok && hasField(res, "total_supply") {
+		return ContractCW20
+	}
+	if res, ok := p.Query(addr, `{"config":{}}`); ok && hasField(res, "staking_token") {
+		return ContractStaking
+	}
+	return ContractUnknown
+}
+
+func hasField(res json.RawMessage, field string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(res, &fields); err != nil {
+		return false
+	}
+	v, ok := fields[field]
+	return ok && !bytes.Equal(v, []byte("null"))
+}
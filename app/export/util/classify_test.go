@@ -0,0 +1,98 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/terra-money/core/app/export/generic/common"
+	wasmtypes "github.com/terra-money/core/x/wasm/types"
+)
+
+// fakeProbe answers queries by their top level key and stores keys by contract.
+type fakeProbe struct {
+	queries map[string]map[string]string
+	keys    map[string][]string
+}
+
+func (p fakeProbe) Query(contract string, msg string) (json.RawMessage, bool) {
+	for key, res := range p.queries[contract] {
+		if strings.HasPrefix(msg, `{"`+key+`"`) {
+			return json.RawMessage(res), true
+		}
+	}
+	return nil, false
+}
+
+func (p fakeProbe) HasPrefix(contract string, prefix []byte) bool {
+	for _, key := range p.keys[contract] {
+		if bytes.HasPrefix([]byte(key), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDetectContractType(t *testing.T) {
+	p := fakeProbe{
+		queries: map[string]map[string]string{
+			"vesting": {"vesting_info": `{}`},
+			"fixed":   {"threshold": `{}`},
+			"flex":    {"threshold": `{}`, "total_weight": `{}`},
+			"group":   {"total_weight": `{}`, "list_members": `{}`},
+			"stake":   {"total_weight": `{}`, "list_members": `{}`, "staked": `{"stake":"1"}`},
+			"subkeys": {"all_allowances": `{}`},
+			"tspair":  {"pair": `{"asset_infos":[]}`},
+			"appair":  {"pair": `{"asset_infos":[],"pair_type":{"xyk":{}}}`, "token_info": `{"total_supply":"1"}`},
+			"token":   {"token_info": `{"total_supply":"1"}`},
+			"staking": {"config": `{"staking_token":"terra1lp"}`},
+			"other":   {"config": `{"owner":"terra1"}`},
+		},
+		keys: map[string][]string{
+			"vesting": {"vesting_info"},
+			"fixed":   {string(GeneratePrefix("voters")) + "terra1voter"},
+		},
+	}
+	expected := map[string]ContractType{
+		"vesting": ContractVesting,
+		"fixed":   ContractCW3Fixed,
+		"flex":    ContractCW3Flex,
+		"group":   ContractCW4Group,
+		"stake":   ContractCW4Stake,
+		"subkeys": ContractCW1Subkeys,
+		"tspair":  ContractTerraswapPair,
+		"appair":  ContractAstroportPair,
+		"token":   ContractCW20,
+		"staking": ContractStaking,
+		"other":   ContractUnknown,
+	}
+	for addr, typ := range expected {
+		if got := detectContractType(p, wasmtypes.ContractInfo{Address: addr}); got != typ {
+			t.Fatalf("expected %s to be %s, got %s", addr, typ, got)
+		}
+	}
+
+	// a code is classified only if its probed contracts agree
+	contracts := common.ContractsMap{}
+	for _, addr := range []string{"group", "stake"} {
+		contracts[addr] = wasmtypes.ContractInfo{Address: addr}
+	}
+	if got := classifyCode(p, contracts, []string{"group", "group", "group", "stake"}); got != ContractCW4Group {
+		t.Fatalf("expected the code to be a group, got %s", got)
+	}
+	if got := classifyCode(p, contracts, []string{"group", "stake", "group"}); got != ContractUnknown {
+		t.Fatalf("expected a disagreeing code to be unknown, got %s", got)
+	}
+
+	table := newContractTable(1, []ContractClass{
+		{CodeID: 2, Type: ContractCW20, Contracts: []string{"b", "a"}},
+		{CodeID: 1, Type: ContractVesting, Contracts: []string{"c"}},
+	})
+	if table.TypeOf("a") != ContractCW20 || table.TypeOf("d") != ContractUnknown {
+		t.Fatal("unexpected contract types")
+	}
+	if got := table.ContractsOf(ContractCW20, ContractVesting); strings.Join(got, ",") != "a,b,c" {
+		t.Fatalf("unexpected contracts %v", got)
+	}
+}
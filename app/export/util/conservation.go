@@ -0,0 +1,122 @@
+package util
+
+import (
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// hubs holds the contracts backing each liquid staking derivative, by denom
+var hubs = make(map[string]string)
+
+// RegisterHub records hub as the contract holding the LUNA that backs denom.
+func RegisterHub(denom string, hub string) {
+	hubs[denom] = hub
+}
+
+// HubOf returns the contract backing denom.
+func HubOf(denom string) (string, bool) {
+	hub, ok := hubs[denom]
+	return hub, ok
+}
+
+// StageTotals sums a snapshot saved by SaveToFile, by denom.
+type StageTotals struct {
+	Stage  string             `json:"stage"`
+	Totals map[string]sdk.Int `json:"totals"`
+	// Blacklisted is the part of Totals held by blacklisted addresses
+	Blacklisted map[string]sdk.Int `json:"blacklisted"`
+	// Hubs is the part of Totals held by derivative hubs that are not blacklisted
+	Hubs map[string]sdk.Int `json:"hubs"`
+	// Balances counts the balances of each denom
+	Balances map[string]int `json:"balances"`
+}
+
+// Eligible returns the part of the total of denom that is paid out to users.
+// Hub holdings are left out, as they are paid out through the derivatives.
+func (t StageTotals) Eligible(denom string) sdk.Int {
+	return orZeroInt(t.Totals[denom]).Sub(orZeroInt(t.Blacklisted[denom])).Sub(orZeroInt(t.Hubs[denom]))
+}
+
+// Denoms returns every denom with a total.
+func (t StageTotals) Denoms() []string {
+	var denoms []string
+	for denom := range t.Totals {
+		denoms = append(denoms, denom)
+	}
+	return denoms
+}
+
+var ledger = struct {
+	mtx    sync.Mutex
+	bl     Blacklist
+	stages map[string]StageTotals
+}{stages: make(map[string]StageTotals)}
+
+// TrackConservation starts recording the totals of every snapshot saved by
+// SaveToFile, splitting out the holdings of addresses blacklisted in bl.
+func TrackConservation(bl Blacklist) {
+	ledger.mtx.Lock()
+	defer ledger.mtx.Unlock()
+	ledger.bl = bl
+	ledger.stages = make(map[string]StageTotals)
+}
+
+// RecordTotals records the totals of a snapshot saved as stage.
+func RecordTotals(stage string, s SnapshotBalanceAggregateMap) {
+	ledger.mtx.Lock()
+	bl := ledger.bl
+	ledger.mtx.Unlock()
+	if bl == nil {
+		return
+	}
+
+	hubAddrs := make(map[string]bool)
+	for _, hub := range hubs {
+		hubAddrs[hub] = true
+	}
+	blacklisted := make(map[string]map[string]bool)
+	t := StageTotals{
+		Stage:       stage,
+		Totals:      make(map[string]sdk.Int),
+		Blacklisted: make(map[string]sdk.Int),
+		Hubs:        make(map[string]sdk.Int),
+		Balances:    make(map[string]int),
+	}
+	for addr, sbs := range s {
+		for _, sb := range sbs {
+			if sb.Balance.IsNil() {
+				continue
+			}
+			if blacklisted[sb.Denom] == nil {
+				blacklisted[sb.Denom] = bl.GetAddressesByDenomMap(sb.Denom)
+			}
+			t.Totals[sb.Denom] = orZeroInt(t.Totals[sb.Denom]).Add(sb.Balance)
+			t.Balances[sb.Denom]++
+			if blacklisted[sb.Denom][addr] {
+				t.Blacklisted[sb.Denom] = orZeroInt(t.Blacklisted[sb.Denom]).Add(sb.Balance)
+			} else if hubAddrs[addr] {
+				t.Hubs[sb.Denom] = orZeroInt(t.Hubs[sb.Denom]).Add(sb.Balance)
+			}
+		}
+	}
+
+	ledger.mtx.Lock()
+	defer ledger.mtx.Unlock()
+	ledger.stages[stage] = t
+}
+
+// RecordedTotals returns the totals recorded for stage.
+func RecordedTotals(stage string) (StageTotals, bool) {
+	ledger.mtx.Lock()
+	defer ledger.mtx.Unlock()
+	t, ok := ledger.stages[stage]
+	return t, ok
+}
+
+func orZeroInt(i sdk.Int) sdk.Int {
+	if i.IsNil() {
+		return sdk.ZeroInt()
+	}
+	return i
+}
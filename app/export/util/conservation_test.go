@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestRecordTotalsSplitsBlacklistAndHubs(t *testing.T) {
+	bl := Blacklist{}
+	bl.RegisterAddress(DenomLUNA, "terra1pair")
+	TrackConservation(bl)
+	defer TrackConservation(nil)
+
+	RegisterHub("utest", "terra1hub")
+	defer delete(hubs, "utest")
+
+	RecordTotals("after-test", SnapshotBalanceAggregateMap{
+		"terra1user": {{Denom: DenomLUNA, Balance: sdk.NewInt(10)}, {Denom: "utest", Balance: sdk.NewInt(5)}},
+		"terra1pair": {{Denom: DenomLUNA, Balance: sdk.NewInt(90)}},
+		"terra1hub":  {{Denom: DenomLUNA, Balance: sdk.NewInt(5)}},
+	})
+	totals, ok := RecordedTotals("after-test")
+	if !ok {
+		t.Fatal("expected totals to be recorded")
+	}
+	if !totals.Totals[DenomLUNA].Equal(sdk.NewInt(105)) {
+		t.Fatalf("expected a total of 105, got %s", totals.Totals[DenomLUNA])
+	}
+	if totals.Balances[DenomLUNA] != 3 {
+		t.Fatalf("expected 3 uluna balances, got %d", totals.Balances[DenomLUNA])
+	}
+	if eligible := totals.Eligible(DenomLUNA); !eligible.Equal(sdk.NewInt(10)) {
+		t.Fatalf("expected 10 eligible, got %s", eligible)
+	}
+	if eligible := totals.Eligible(DenomUST); !eligible.IsZero() {
+		t.Fatalf("expected nothing eligible for a missing denom, got %s", eligible)
+	}
+
+	from, to, ok := ConversionOf(DenomConversion(DenomSTEAK, DenomLUNA))
+	if !ok || from != DenomSTEAK || to != DenomLUNA {
+		t.Fatalf("unexpected conversion %s → %s", from, to)
+	}
+	if _, _, ok := ConversionOf(SnapshotOf("steak")); ok {
+		t.Fatal("expected a snapshot not to be a conversion")
+	}
+}
@@ -1,6 +1,10 @@
 package util
 
-import "github.com/terra-money/core/app/export/generic/common"
+import (
+	"sort"
+
+	"github.com/terra-money/core/app/export/generic/common"
+)
 
 // bridge addresses
 var contractWhitelist = map[string]bool{
@@ -11,11 +15,27 @@ var contractWhitelist = map[string]bool{
 	"terra1qwzdua7928ugklpytdzhua92gnkxp9z4vhelq8": true,
 }
 
-// RemoveContractBalances removes contract holding from snapshot, except for the whitelists
-func RemoveContractBalances(snapshot SnapshotBalanceAggregateMap, contractMap common.ContractsMap) {
-	for contractAddress, _ := range contractMap {
-		if _, whitelist := contractWhitelist[contractAddress]; !whitelist {
-			delete(snapshot, contractAddress)
+// RemoveContractBalances removes contract holding from snapshot, except for the
+// whitelists and any extra whitelisted contracts
+func RemoveContractBalances(snapshot SnapshotBalanceAggregateMap, contractMap common.ContractsMap, extraWhitelist ...string) {
+	for _, contractAddress := range RemovedContracts(contractMap, extraWhitelist...) {
+		delete(snapshot, contractAddress)
+	}
+}
+
+// RemovedContracts lists the contracts whose holdings RemoveContractBalances
+// removes, sorted.
+func RemovedContracts(contractMap common.ContractsMap, extraWhitelist ...string) []string {
+	extra := make(map[string]bool)
+	for _, addr := range extraWhitelist {
+		extra[addr] = true
+	}
+	var removed []string
+	for contractAddress := range contractMap {
+		if _, whitelist := contractWhitelist[contractAddress]; !whitelist && !extra[contractAddress] {
+			removed = append(removed, contractAddress)
 		}
 	}
+	sort.Strings(removed)
+	return removed
 }
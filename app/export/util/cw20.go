@@ -0,0 +1,122 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/storage"
+)
+
+// CW20Report records how the holders of a cw20 were read from raw state.
+type CW20Report struct {
+	Contract string             `json:"contract"`
+	Layout   storage.CW20Layout `json:"layout"`
+	Holders  int                `json:"holders"`
+	// Total sums the balances read, to be checked against Supply
+	Total  sdk.Int `json:"total"`
+	Supply sdk.Int `json:"supply"`
+	// SupplyError is why token_info failed, leaving Supply unset
+	SupplyError string                    `json:"supply_error,omitempty"`
+	Unparsable  []storage.UnparsableEntry `json:"unparsable,omitempty"`
+}
+
+// cw20Collector collects the reports of the cw20s read by one exporter.
+type cw20Collector struct {
+	mtx     sync.Mutex
+	reports map[string]CW20Report
+}
+
+type cw20CollectorKey struct{}
+
+func (c *cw20Collector) record(r CW20Report) {
+	if c == nil {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.reports[r.Contract] = r
+}
+
+// cw20Collectors are the collectors of the running CollectCW20 calls, by the
+// app handed to each.
+var cw20Collectors = struct {
+	mtx   sync.Mutex
+	byApp map[*terra.TerraApp]*cw20Collector
+}{byApp: make(map[*terra.TerraApp]*cw20Collector)}
+
+// cw20CollectorOf returns the collector of app, nil outside CollectCW20.
+func cw20CollectorOf(app *terra.TerraApp) *cw20Collector {
+	cw20Collectors.mtx.Lock()
+	defer cw20Collectors.mtx.Unlock()
+	return cw20Collectors.byApp[app]
+}
+
+// cw20CollectorFrom returns the collector carried by ctx, nil if none.
+func cw20CollectorFrom(ctx context.Context) *cw20Collector {
+	c, _ := ctx.Value(cw20CollectorKey{}).(*cw20Collector)
+	return c
+}
+
+// CollectCW20 runs f with its own handle on app and returns the reports of
+// every cw20 read through the contexts PrepCtx builds from it, sorted by
+// contract. Exporters running at the same time hold different handles, so
+// each collects only its own reports.
+func CollectCW20(app *terra.TerraApp, f func(*terra.TerraApp) error) ([]CW20Report, error) {
+	scoped := app
+	if app != nil {
+		// exporters only use the app to read state, which the copy shares
+		copied := *app
+		scoped = &copied
+	}
+	c := &cw20Collector{reports: make(map[string]CW20Report)}
+	cw20Collectors.mtx.Lock()
+	cw20Collectors.byApp[scoped] = c
+	cw20Collectors.mtx.Unlock()
+
+	err := f(scoped)
+
+	cw20Collectors.mtx.Lock()
+	delete(cw20Collectors.byApp, scoped)
+	cw20Collectors.mtx.Unlock()
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return sortedCW20(c.reports), err
+}
+
+func sortedCW20(reports map[string]CW20Report) []CW20Report {
+	sorted := make([]CW20Report, 0, len(reports))
+	for _, r := range reports {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Contract < sorted[j].Contract })
+	return sorted
+}
+
+// AuditCW20Reports checks that the balances read of every cw20 in the outputs
+// of state add up to its supply and that none failed to decode, and saves the
+// reports as cw20-holders.json in folder. A cw20 whose supply could not be
+// queried fails the supply check.
+func AuditCW20Reports(state *ExportState, audit *Audit, folder string) error {
+	union := make(map[string]CW20Report)
+	for _, name := range state.OutputNames() {
+		for _, r := range state.Output(name).CW20Reports {
+			union[r.Contract] = r
+		}
+	}
+	reports := sortedCW20(union)
+	for _, r := range reports {
+		audit.Check(NewInvariant(r.Contract+" balances add up to supply", SeverityWarn, 1), r.Supply, r.Total)
+		audit.Check(NewInvariant(r.Contract+" balances decode", SeverityWarn, 1), sdk.ZeroInt(), sdk.NewInt(int64(len(r.Unparsable))))
+	}
+	out, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(folder, "cw20-holders.json"), out, 0666)
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cosmos/cosmos-sdk/types"
@@ -17,7 +19,10 @@ import (
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	tmjson "github.com/tendermint/tendermint/libs/json"
 	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tmstore "github.com/tendermint/tendermint/store"
+	dbm "github.com/tendermint/tm-db"
 	terra "github.com/terra-money/core/app"
+	"github.com/terra-money/core/app/export/storage"
 	wasmkeeper "github.com/terra-money/core/x/wasm/keeper"
 	wasmtypes "github.com/terra-money/core/x/wasm/types"
 )
@@ -46,30 +51,8 @@ var (
 	AUST = "terra1hzh9vpxhsk8253se0vv5jj6etdvxu3nv8z07zu"
 )
 
-var contractToDenomMap map[string]string
 var SmartContractsAddresses map[string]wasmtypes.ContractInfo
 
-func init() {
-	contractToDenomMap = make(map[string]string)
-	contractToDenomMap["terra1tlgelulz9pdkhls6uglfn5lmxarx7f2gxtdzh2"] = DenomPLUNA
-	contractToDenomMap["terra17y9qkl8dfkeg4py7n0g5407emqnemc3yqk5rup"] = DenomLUNAX
-	contractToDenomMap["terra13zaagrrrxj47qjwczsczujlvnnntde7fdt0mau"] = DenomCLUNA
-	contractToDenomMap["terra13zaagrrrxj47qjwczsczujlvnnntde7fdt0mau"] = DenomCLUNA
-	contractToDenomMap["terra1kc87mu460fwkqte29rquh4hc20m54fxwtsx7gp"] = DenomBLUNA
-	contractToDenomMap["terra1yg3j2s986nyp5z7r2lvt0hx3r0lnd7kwvwwtsc"] = DenomSTLUNA
-	contractToDenomMap["uluna"] = DenomLUNA
-	contractToDenomMap["uusd"] = DenomUST
-	contractToDenomMap[AUST] = DenomAUST
-}
-
-func MapContractToDenom(addr string) string {
-	denom, ok := contractToDenomMap[addr]
-	if !ok {
-		panic(fmt.Errorf("contract %s not mapped to denom", addr))
-	}
-	return denom
-}
-
 type allAccountsResponse struct {
 	Accounts []string `json:"accounts"`
 }
@@ -99,22 +82,76 @@ func GetBalance(account string) json.RawMessage {
 	return []byte(fmt.Sprintf("{\"balance\":{\"address\":\"%s\"}}", account))
 }
 
-var timestampsPerBlock = map[int64]time.Time{
-	7544910: time.Unix(1651935577, 792),
-	7790000: time.Unix(1653583088, 146),
+// timestampsPerBlock overrides the block times read from the block store
+var timestampsPerBlock = make(map[int64]time.Time)
+
+var (
+	blockTimeMtx sync.Mutex
+	// block times loaded from the block store, by height
+	storedBlockTimes  = make(map[int64]time.Time)
+	blockStoreDir     string
+	blockStoreBackend dbm.BackendType
+)
+
+// SetBlockTime overrides the block time PrepCtx uses for height.
+func SetBlockTime(height int64, t time.Time) {
+	blockTimeMtx.Lock()
+	defer blockTimeMtx.Unlock()
+	timestampsPerBlock[height] = t
+}
+
+// UseBlockStore makes PrepCtx read block times from the Tendermint block store
+// in the node's data directory.
+func UseBlockStore(dataDir string, backend string) {
+	blockTimeMtx.Lock()
+	defer blockTimeMtx.Unlock()
+	blockStoreDir = dataDir
+	blockStoreBackend = dbm.BackendType(backend)
+}
+
+// BlockTime returns the time of the block at height, from the overrides or
+// else from the block store.
+func BlockTime(height int64) (time.Time, error) {
+	blockTimeMtx.Lock()
+	defer blockTimeMtx.Unlock()
+	if t, ok := timestampsPerBlock[height]; ok {
+		return t, nil
+	}
+	if t, ok := storedBlockTimes[height]; ok {
+		return t, nil
+	}
+	if blockStoreDir == "" {
+		return time.Time{}, fmt.Errorf("unknown block time for height %d, set block_time in the snapshot profile", height)
+	}
 
-	// test
-	7684654: time.Unix(1652926192, 483),
+	db, err := dbm.NewDB("blockstore", blockStoreBackend, blockStoreDir)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to open block store: %v", err)
+	}
+	defer db.Close()
+	meta := tmstore.NewBlockStore(db).LoadBlockMeta(height)
+	if meta == nil {
+		return time.Time{}, fmt.Errorf("block store in %s has no block %d, set block_time in the snapshot profile", blockStoreDir, height)
+	}
+	storedBlockTimes[height] = meta.Header.Time
+	return meta.Header.Time, nil
 }
 
+// PrepCtx returns a query context over a fresh cache of the committed state,
+// so exporters running concurrently never share a store. It carries the cw20
+// collector of app, see CollectCW20.
 func PrepCtx(app *terra.TerraApp) context.Context {
 	height := app.LastBlockHeight()
-	time, ok := timestampsPerBlock[height]
-	if !ok {
-		panic(fmt.Sprintf("Unknown target height %d", height))
+	time, err := BlockTime(height)
+	if err != nil {
+		panic(err)
 	}
 
-	ctx := app.NewContext(true, tmproto.Header{Height: height, Time: time})
+	ctx := app.NewUncachedContext(true, tmproto.Header{Height: height, Time: time})
+	ctx = ctx.WithMultiStore(ctx.MultiStore().CacheMultiStore())
+	if c := cw20CollectorOf(app); c != nil {
+		ctx = ctx.WithValue(cw20CollectorKey{}, c)
+	}
 	return sdktypes.WrapSDKContext(ctx)
 }
 
@@ -151,6 +188,29 @@ func GetNativeBalance(ctx context.Context, k wasmtypes.BankKeeper, denom string,
 	return coin.Amount, nil
 }
 
+// GetLunaBacking returns the LUNA delegated, unbonding and held by account.
+func GetLunaBacking(ctx context.Context, app *terra.TerraApp, account string) (sdk.Int, error) {
+	accountAddr, err := sdk.AccAddressFromBech32(account)
+	if err != nil {
+		return sdk.ZeroInt(), err
+	}
+	uCtx := sdk.UnwrapSDKContext(ctx)
+	total := app.BankKeeper.GetBalance(uCtx, accountAddr, DenomLUNA).Amount
+	for _, del := range app.StakingKeeper.GetDelegatorDelegations(uCtx, accountAddr, math.MaxUint16) {
+		v, ok := app.StakingKeeper.GetValidator(uCtx, del.GetValidatorAddr())
+		if !ok {
+			return sdk.ZeroInt(), fmt.Errorf("validator %s of %s not found", del.ValidatorAddress, account)
+		}
+		total = total.Add(v.TokensFromShares(del.Shares).TruncateInt())
+	}
+	for _, ubd := range app.StakingKeeper.GetUnbondingDelegations(uCtx, accountAddr, math.MaxUint16) {
+		for _, entry := range ubd.Entries {
+			total = total.Add(entry.Balance)
+		}
+	}
+	return total, nil
+}
+
 func GetCW20Balance(ctx context.Context, q wasmtypes.QueryServer, cw20Addr string, holder string) (sdktypes.Int, error) {
 	var balance struct {
 		Balance sdk.Int `json:"balance"`
@@ -167,25 +227,39 @@ func GetCW20Balance(ctx context.Context, q wasmtypes.QueryServer, cw20Addr strin
 
 var GetCW20AccountsAndBalances = GetCW20AccountsAndBalances2
 
+// GetCW20AccountsAndBalances2 reads the balance of every holder of a cw20 from
+// raw state, detecting how the contract stores them. The layout used, entries
+// that do not decode and whether the balances add up to the total supply are
+// reported to the CollectCW20 call ctx was prepared under.
 func GetCW20AccountsAndBalances2(ctx context.Context, keeper wasmkeeper.Keeper, contractAddress string, balanceMap map[string]sdktypes.Int) error {
-	prefix := GeneratePrefix("balance")
-	contractAddr, err := sdktypes.AccAddressFromBech32(contractAddress)
+	store, err := storage.NewContractStore(ctx, keeper, contractAddress)
 	if err != nil {
 		return err
 	}
-	keeper.IterateContractStateWithPrefix(sdk.UnwrapSDKContext(ctx), contractAddr, prefix, func(key, value []byte) bool {
-		// first and last byte is not used
-		balance, ok := sdktypes.NewIntFromString(string(value[1 : len(value)-1]))
-		// fmt.Printf("%x, %x, %s, %v\n", key, value, balance, ok)
-		if ok {
-			if strings.Contains(string(key), "terra") {
-				balanceMap[string(key)] = balance
-			} else {
-				addr := sdk.AccAddress(key)
-				balanceMap[addr.String()] = balance
-			}
+	// a token that does not answer token_info is still read, and reported
+	var supplyErr string
+	supply, err := GetCW20TotalSupply(ctx, wasmkeeper.NewQuerier(keeper), contractAddress)
+	if err != nil {
+		supplyErr = err.Error()
+	}
+	balances, err := storage.ReadCW20Balances(store)
+	if err != nil {
+		if supplyErr == "" && supply.IsZero() {
+			return nil
 		}
-		return false
+		return fmt.Errorf("cw20 %s: %v", contractAddress, err)
+	}
+	for holder, balance := range balances.Balances {
+		balanceMap[holder] = balance
+	}
+	cw20CollectorFrom(ctx).record(CW20Report{
+		Contract:    contractAddress,
+		Layout:      balances.Layout,
+		Holders:     len(balances.Balances),
+		Total:       balances.Total(),
+		Supply:      supply,
+		SupplyError: supplyErr,
+		Unparsable:  balances.Unparsable,
 	})
 	return nil
 }
@@ -332,31 +406,12 @@ func Xor(b1 map[string]sdk.Int, b2 map[string]sdk.Int) (b3 map[string][]sdk.Int)
 	return b3
 }
 
-func AssertCw20Supply(ctx context.Context, q wasmtypes.QueryServer, cw20Addr string, holdings BalanceMap) error {
-	var tokenInfo struct {
-		TotalSupply sdk.Int `json:"total_supply"`
-	}
-	ContractQuery(ctx, q, &wasmtypes.QueryContractStoreRequest{
-		ContractAddress: cw20Addr,
-		QueryMsg:        []byte("{\"token_info\":{}}"),
-	}, &tokenInfo)
-	if err := AlmostEqual(fmt.Sprintf("token %s supply doesnt match\n", cw20Addr), tokenInfo.TotalSupply, Sum(holdings), sdk.NewInt(2000000)); err != nil {
-		return err
-	}
-	return nil
-}
-
-func AssertNativeSupply(ctx context.Context, b bankkeeper.Keeper, denom string, holdings BalanceMap) error {
+func GetNativeSupply(ctx context.Context, b bankkeeper.Keeper, denom string) (sdk.Int, error) {
 	supply, err := b.SupplyOf(ctx, &banktypes.QuerySupplyOfRequest{Denom: denom})
 	if err != nil {
-		return err
-	}
-
-	if err := AlmostEqual(fmt.Sprintf("token %s supply doesnt match", denom), supply.Amount.Amount, Sum(holdings), sdk.NewInt(2000000)); err != nil {
-		return err
+		return sdk.Int{}, err
 	}
-
-	panic(fmt.Errorf("denom %s not found", denom))
+	return supply.Amount.Amount, nil
 }
 
 func SaveDataToFile(file string, data interface{}) error {
@@ -371,43 +426,7 @@ func SaveDataToFile(file string, data interface{}) error {
 	return nil
 }
 
-func CachedDex(f func(*terra.TerraApp, Blacklist, map[string]map[string]map[string]sdk.Int) (SnapshotBalanceAggregateMap, error), filename string, app *terra.TerraApp, bl Blacklist, lpMap map[string]map[string]map[string]sdk.Int) (SnapshotBalanceAggregateMap, error) {
-	folder := fmt.Sprintf("./cache-%d", app.LastBlockHeight())
-	_ = os.Mkdir(folder, 0777)
-	path := fmt.Sprintf("%s/%s", folder, filename)
-	summaryPath := fmt.Sprintf("%s/summary.csv", folder)
-	if _, err := os.Stat(path); err == nil {
-		data, err := os.ReadFile(path)
-		if err == nil {
-			var snapshot SnapshotBalanceAggregateMap
-			if err = json.Unmarshal(data, &snapshot); err == nil {
-				err = SummarizeProtocolTotals(snapshot, summaryPath, filename)
-				if err != nil {
-					return nil, err
-				}
-				return snapshot, nil
-			}
-		}
-	}
-	snapshot, err := f(app, bl, lpMap)
-	if err != nil {
-		return nil, err
-	}
-	out, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return nil, err
-	}
-	err = os.WriteFile(path, out, 0666)
-	if err != nil {
-		return nil, err
-	}
-	err = SummarizeProtocolTotals(snapshot, summaryPath, filename)
-	if err != nil {
-		return nil, err
-	}
-
-	return snapshot, nil
-}
+var summaryMtx sync.Mutex
 
 func SummarizeProtocolTotals(aggregateMap SnapshotBalanceAggregateMap, filePath string, protocol string) error {
 	totals := make(map[string]sdk.Int)
@@ -447,6 +466,8 @@ func SummarizeProtocolTotals(aggregateMap SnapshotBalanceAggregateMap, filePath
 		balances = append(balances, b.Balance.String())
 	}
 	line := fmt.Sprintf("%s\n", strings.Join(balances, ","))
+	summaryMtx.Lock()
+	defer summaryMtx.Unlock()
 	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
@@ -456,90 +477,8 @@ func SummarizeProtocolTotals(aggregateMap SnapshotBalanceAggregateMap, filePath
 	return err
 }
 
-func CachedSBA(f func(*terra.TerraApp, Blacklist) (SnapshotBalanceAggregateMap, error), filename string, app *terra.TerraApp, bl Blacklist) (SnapshotBalanceAggregateMap, error) {
-	folder := fmt.Sprintf("./cache-%d", app.LastBlockHeight())
-	_ = os.Mkdir(folder, 0777)
-	path := fmt.Sprintf("%s/%s", folder, filename)
-	summaryPath := fmt.Sprintf("%s/summary.csv", folder)
-	if _, err := os.Stat(path); err == nil {
-		data, err := os.ReadFile(path)
-		if err == nil {
-			var snapshot SnapshotBalanceAggregateMap
-			if err = json.Unmarshal(data, &snapshot); err == nil {
-				err = SummarizeProtocolTotals(snapshot, summaryPath, filename)
-				if err != nil {
-					return nil, err
-				}
-				return snapshot, nil
-			}
-		}
-	}
-	snapshot, err := f(app, bl)
-	if err != nil {
-		return nil, err
-	}
-	out, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return nil, err
-	}
-	err = os.WriteFile(path, out, 0666)
-	if err != nil {
-		return nil, err
-	}
-	err = SummarizeProtocolTotals(snapshot, summaryPath, filename)
-	if err != nil {
-		return nil, err
-	}
-
-	return snapshot, nil
-}
-
-func CachedMap3(f func(*terra.TerraApp, SnapshotBalanceAggregateMap) (map[string]map[string]map[string]sdk.Int, error), filename string, app *terra.TerraApp, snapshot SnapshotBalanceAggregateMap) (map[string]map[string]map[string]sdk.Int, error) {
-	folder := fmt.Sprintf("./cache-%d", app.LastBlockHeight())
-	_ = os.Mkdir(folder, 0777)
-	path := fmt.Sprintf("%s/%s", folder, filename)
-	ssPath := fmt.Sprintf("%s-snapshot", path)
-	if _, err := os.Stat(path); err == nil {
-		ssData, err := os.ReadFile(ssPath)
-		if err == nil {
-			var ss SnapshotBalanceAggregateMap
-			if err = json.Unmarshal(ssData, &ss); err != nil {
-				panic(err)
-			}
-		}
-		data, err := os.ReadFile(path)
-		if err == nil {
-			var lpHolding map[string]map[string]map[string]sdk.Int
-			if err = json.Unmarshal(data, &lpHolding); err == nil {
-				return lpHolding, nil
-			}
-		}
-	}
-	lpHolding, err := f(app, snapshot)
-	if err != nil {
-		return nil, err
-	}
-	out, err := json.MarshalIndent(lpHolding, "", "  ")
-	if err != nil {
-		return nil, err
-	}
-	err = os.WriteFile(path, out, 0666)
-	if err != nil {
-		return nil, err
-	}
-	ssOut, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
-		return nil, err
-	}
-	err = os.WriteFile(ssPath, ssOut, 0666)
-	if err != nil {
-		return nil, err
-	}
-	return lpHolding, nil
-}
-
 func SaveToFile(app *terra.TerraApp, snapshot SnapshotBalanceAggregateMap, filename string) error {
-	folder := fmt.Sprintf("./cache-%d", app.LastBlockHeight())
+	folder := CacheFolder(app.LastBlockHeight())
 	_ = os.Mkdir(folder, 0777)
 	path := fmt.Sprintf("%s/%s", folder, filename)
 	out, err := json.MarshalIndent(snapshot, "", "  ")
@@ -550,12 +489,19 @@ func SaveToFile(app *terra.TerraApp, snapshot SnapshotBalanceAggregateMap, filen
 	if err != nil {
 		return err
 	}
+	RecordTotals(filename, snapshot)
 	return nil
 }
 
-func AssertZeroSupply(snapshot SnapshotBalanceAggregateMap, denom string) {
-	s := Sum(snapshot.FilterByDenom(denom))
-	if !s.IsZero() {
-		panic(fmt.Errorf("total supply invariant: denom %s exsists: %s", denom, s.String()))
+// LoadFromFile reads a snapshot written by SaveToFile at the given height.
+func LoadFromFile(height int64, filename string) (SnapshotBalanceAggregateMap, error) {
+	data, err := os.ReadFile(fmt.Sprintf("%s/%s", CacheFolder(height), filename))
+	if err != nil {
+		return nil, err
 	}
+	var snapshot SnapshotBalanceAggregateMap
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
 }
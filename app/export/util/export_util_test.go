@@ -0,0 +1,46 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	tmstore "github.com/tendermint/tendermint/store"
+	tmtypes "github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestBlockTimeFromBlockStore(t *testing.T) {
+	dir := t.TempDir()
+	blockTime := time.Date(2022, 5, 26, 16, 38, 8, 146, time.UTC)
+
+	db, err := dbm.NewDB("blockstore", dbm.GoLevelDBBackend, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := tmtypes.MakeBlock(42, nil, &tmtypes.Commit{}, nil)
+	block.Header.Time = blockTime
+	block.Header.ProposerAddress = make([]byte, 20)
+	tmstore.NewBlockStore(db).SaveBlock(block, block.MakePartSet(tmtypes.BlockPartSizeBytes), &tmtypes.Commit{Height: 42})
+	db.Close()
+
+	UseBlockStore(dir, string(dbm.GoLevelDBBackend))
+	defer UseBlockStore("", "")
+
+	got, err := BlockTime(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(blockTime) {
+		t.Fatalf("expected %s, got %s", blockTime, got)
+	}
+	if _, err := BlockTime(43); err == nil {
+		t.Fatal("expected an error for a height missing from the block store")
+	}
+
+	override := blockTime.Add(time.Hour)
+	SetBlockTime(43, override)
+	defer delete(timestampsPerBlock, 43)
+	if got, err := BlockTime(43); err != nil || !got.Equal(override) {
+		t.Fatalf("expected the override %s, got %s (%v)", override, got, err)
+	}
+}
@@ -0,0 +1,378 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	terra "github.com/terra-money/core/app"
+)
+
+type ExporterKind string
+
+const (
+	// KindLPCompounder exporters return vault -> lp -> user -> amount maps
+	// that are spliced into the DEX exporters.
+	KindLPCompounder ExporterKind = "lp-compounder"
+	// KindDEX exporters decompose LP positions, after compounder vaults are resolved.
+	KindDEX ExporterKind = "dex"
+	// KindSBA exporters return a snapshot of user balances.
+	KindSBA ExporterKind = "sba"
+	// KindMerge stages combine the protocol snapshots.
+	KindMerge ExporterKind = "merge"
+	// KindFinalize stages turn the resolved snapshot into the final balances.
+	KindFinalize ExporterKind = "finalize"
+	// KindResolver exporters rewrite the merged snapshot in place.
+	KindResolver ExporterKind = "resolver"
+)
+
+// IsProtocol reports whether exporters of this kind read chain state into their
+// own output, as opposed to rewriting the shared merged snapshot.
+func (k ExporterKind) IsProtocol() bool {
+	return k == KindLPCompounder || k == KindDEX || k == KindSBA
+}
+
+// ExportOutput holds whatever an exporter produced.
+type ExportOutput struct {
+	Snapshot   SnapshotBalanceAggregateMap              `json:"snapshot,omitempty"`
+	LpHoldings map[string]map[string]map[string]sdk.Int `json:"lp_holdings,omitempty"`
+	// BlacklistDelta lists the addresses the exporter registered, replayed on cache hits
+	BlacklistDelta Blacklist `json:"blacklist_delta,omitempty"`
+	// Checks are the invariants checked while exporting, replayed on cache hits
+	Checks []AuditResult `json:"checks,omitempty"`
+	// CW20Reports are the cw20s read by the exporter, see CollectCW20
+	CW20Reports []CW20Report `json:"cw20_reports,omitempty"`
+}
+
+// ExportState is threaded through the pipeline so exporters can read the
+// outputs of the exporters they depend on.
+type ExportState struct {
+	SnapshotType Snapshot
+	// CompoundedLps merges the outputs of all lp-compounder exporters
+	CompoundedLps map[string]map[string]map[string]sdk.Int
+	// Snapshot is the merged snapshot that resolvers rewrite
+	Snapshot SnapshotBalanceAggregateMap
+	// Audit collects the audit results of every stage
+	Audit *AuditReport
+	// Folder is where stages write their reports, next to the cached outputs
+	Folder string
+
+	mtx sync.RWMutex
+	// outputs of every exporter that has run, by exporter name
+	outputs map[string]ExportOutput
+}
+
+func NewExportState(snapshotType Snapshot) *ExportState {
+	return &ExportState{
+		SnapshotType:  snapshotType,
+		outputs:       make(map[string]ExportOutput),
+		CompoundedLps: make(map[string]map[string]map[string]sdk.Int),
+		Snapshot:      make(SnapshotBalanceAggregateMap),
+		Audit:         NewAuditReport(),
+	}
+}
+
+// Output returns the output of the named exporter.
+func (s *ExportState) Output(name string) ExportOutput {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.outputs[name]
+}
+
+// SetOutput records the output of the named exporter. Compounder holdings are
+// merged into CompoundedLps, which must not be read until all compounders are done.
+func (s *ExportState) SetOutput(name string, out ExportOutput) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.outputs[name] = out
+	for k, v := range out.LpHoldings {
+		s.CompoundedLps[k] = v
+	}
+}
+
+// OutputNames returns the names of exporters that have run, sorted.
+func (s *ExportState) OutputNames() []string {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	names := make([]string, 0, len(s.outputs))
+	for name := range s.outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Exporter is implemented by every protocol exporter under app/export.
+// Exporters register themselves with RegisterExporter from an init function.
+type Exporter interface {
+	Name() string
+	Kind() ExporterKind
+	// Version is bumped whenever the exporter logic changes, invalidating its cache entries
+	Version() int
+	// Inputs lists what the exporter consumes; it runs after every producer of each input
+	Inputs() []Resource
+	// Outputs lists what the exporter produces
+	Outputs() []Resource
+	// Enabled reports whether the exporter applies to the given snapshot type
+	Enabled(snapshotType Snapshot) bool
+	Export(app *terra.TerraApp, bl Blacklist, state *ExportState) (ExportOutput, error)
+	// Audit checks the invariants of out, recording them in audit. An error means
+	// the invariants could not be checked.
+	Audit(app *terra.TerraApp, out ExportOutput, audit *Audit) error
+}
+
+type (
+	SBAExportFunc        func(*terra.TerraApp, Blacklist) (SnapshotBalanceAggregateMap, error)
+	DexExportFunc        func(*terra.TerraApp, Blacklist, map[string]map[string]map[string]sdk.Int) (SnapshotBalanceAggregateMap, error)
+	AuditedExportFunc    func(*terra.TerraApp, Blacklist, map[string]map[string]map[string]sdk.Int, *Audit) (SnapshotBalanceAggregateMap, error)
+	CompounderExportFunc func(*terra.TerraApp, SnapshotBalanceAggregateMap) (map[string]map[string]map[string]sdk.Int, error)
+	ResolverFunc         func(*terra.TerraApp, SnapshotBalanceAggregateMap, Blacklist) error
+	AuditedResolverFunc  func(*terra.TerraApp, SnapshotBalanceAggregateMap, Blacklist, *Audit) error
+
+	SnapshotAuditFunc func(*terra.TerraApp, SnapshotBalanceAggregateMap, *Audit) error
+	LpAuditFunc       func(*terra.TerraApp, map[string]map[string]map[string]sdk.Int, *Audit) error
+)
+
+// FuncExporter adapts plain export and audit functions to the Exporter interface.
+type FuncExporter struct {
+	name      string
+	kind      ExporterKind
+	inputs    []Resource
+	outputs   []Resource
+	snapshots []Snapshot
+	version   int
+	export    func(*terra.TerraApp, Blacklist, *ExportState) (ExportOutput, error)
+	audit     func(*terra.TerraApp, ExportOutput, *Audit) error
+}
+
+var _ Exporter = (*FuncExporter)(nil)
+
+// NewExporter creates an exporter with full access to the pipeline state.
+// Inputs and outputs implied by the kind are declared automatically.
+func NewExporter(name string, kind ExporterKind, f func(*terra.TerraApp, Blacklist, *ExportState) (ExportOutput, error)) *FuncExporter {
+	e := &FuncExporter{
+		name:    name,
+		kind:    kind,
+		version: 1,
+		export:  f,
+	}
+	switch kind {
+	case KindLPCompounder:
+		e.Produces(LpHoldingsOf(name), ResourceCompoundedLps)
+	case KindDEX:
+		e.Consumes(ResourceCompoundedLps).Produces(SnapshotOf(name))
+	case KindSBA:
+		e.Produces(SnapshotOf(name))
+	case KindResolver:
+		e.Consumes(ResourceMergedSnapshot)
+	}
+	return e
+}
+
+// NewSBAExporter adapts a function returning a snapshot of user balances.
+func NewSBAExporter(name string, f SBAExportFunc, audit SnapshotAuditFunc) *FuncExporter {
+	return NewExporter(name, KindSBA, func(app *terra.TerraApp, bl Blacklist, _ *ExportState) (ExportOutput, error) {
+		snapshot, err := f(app, bl)
+		return ExportOutput{Snapshot: snapshot}, err
+	}).WithSnapshotAudit(audit)
+}
+
+// NewDexExporter adapts a DEX export function, feeding it the merged compounder holdings.
+func NewDexExporter(name string, f DexExportFunc, audit SnapshotAuditFunc) *FuncExporter {
+	return NewExporter(name, KindDEX, func(app *terra.TerraApp, bl Blacklist, state *ExportState) (ExportOutput, error) {
+		snapshot, err := f(app, bl, state.CompoundedLps)
+		return ExportOutput{Snapshot: snapshot}, err
+	}).WithSnapshotAudit(audit)
+}
+
+// NewAuditedExporter adapts an SBA or DEX export function that checks invariants
+// its output alone cannot show. SBA exporters get no compounder holdings.
+func NewAuditedExporter(name string, kind ExporterKind, f AuditedExportFunc) *FuncExporter {
+	return NewExporter(name, kind, func(app *terra.TerraApp, bl Blacklist, state *ExportState) (ExportOutput, error) {
+		var lps map[string]map[string]map[string]sdk.Int
+		if kind == KindDEX {
+			lps = state.CompoundedLps
+		}
+		report := NewAuditReport()
+		snapshot, err := f(app, bl, lps, report.For(name))
+		return ExportOutput{Snapshot: snapshot, Checks: report.Results()}, err
+	})
+}
+
+// NewCompounderExporter adapts a function returning vault -> lp -> user -> amount holdings.
+func NewCompounderExporter(name string, f CompounderExportFunc, audit LpAuditFunc) *FuncExporter {
+	e := NewExporter(name, KindLPCompounder, func(app *terra.TerraApp, _ Blacklist, state *ExportState) (ExportOutput, error) {
+		lpHoldings, err := f(app, make(SnapshotBalanceAggregateMap))
+		return ExportOutput{LpHoldings: lpHoldings}, err
+	})
+	if audit != nil {
+		e.audit = func(app *terra.TerraApp, out ExportOutput, a *Audit) error {
+			return audit(app, out.LpHoldings, a)
+		}
+	}
+	return e
+}
+
+// NewResolverExporter runs f against the merged snapshot and saves the result as after-<name>.
+func NewResolverExporter(name string, f ResolverFunc) *FuncExporter {
+	return NewExporter(name, KindResolver, func(app *terra.TerraApp, bl Blacklist, state *ExportState) (ExportOutput, error) {
+		if err := f(app, state.Snapshot, bl); err != nil {
+			return ExportOutput{}, err
+		}
+		state.Snapshot.Attribute(name)
+		return ExportOutput{}, SaveToFile(app, state.Snapshot, fmt.Sprintf("after-%s", name))
+	})
+}
+
+// NewAuditedResolverExporter is NewResolverExporter for a resolver that checks
+// invariants of the snapshot it rewrites.
+func NewAuditedResolverExporter(name string, f AuditedResolverFunc) *FuncExporter {
+	return NewExporter(name, KindResolver, func(app *terra.TerraApp, bl Blacklist, state *ExportState) (ExportOutput, error) {
+		report := NewAuditReport()
+		if err := f(app, state.Snapshot, bl, report.For(name)); err != nil {
+			return ExportOutput{Checks: report.Results()}, err
+		}
+		state.Snapshot.Attribute(name)
+		return ExportOutput{Checks: report.Results()}, SaveToFile(app, state.Snapshot, fmt.Sprintf("after-%s", name))
+	})
+}
+
+// Consumes declares additional inputs.
+func (e *FuncExporter) Consumes(rs ...Resource) *FuncExporter {
+	e.inputs = append(e.inputs, rs...)
+	return e
+}
+
+// Produces declares additional outputs.
+func (e *FuncExporter) Produces(rs ...Resource) *FuncExporter {
+	e.outputs = append(e.outputs, rs...)
+	return e
+}
+
+// OnlyFor restricts the exporter to the given snapshot types.
+func (e *FuncExporter) OnlyFor(snapshots ...Snapshot) *FuncExporter {
+	e.snapshots = append(e.snapshots, snapshots...)
+	return e
+}
+
+// WithVersion sets the exporter version recorded in the cache manifest.
+func (e *FuncExporter) WithVersion(version int) *FuncExporter {
+	e.version = version
+	return e
+}
+
+func (e *FuncExporter) WithSnapshotAudit(audit SnapshotAuditFunc) *FuncExporter {
+	if audit != nil {
+		e.audit = func(app *terra.TerraApp, out ExportOutput, a *Audit) error {
+			return audit(app, out.Snapshot, a)
+		}
+	}
+	return e
+}
+
+func (e *FuncExporter) Name() string {
+	return e.name
+}
+
+func (e *FuncExporter) Kind() ExporterKind {
+	return e.kind
+}
+
+func (e *FuncExporter) Version() int {
+	return e.version
+}
+
+func (e *FuncExporter) Inputs() []Resource {
+	return e.inputs
+}
+
+func (e *FuncExporter) Outputs() []Resource {
+	return e.outputs
+}
+
+func (e *FuncExporter) Enabled(snapshotType Snapshot) bool {
+	if len(e.snapshots) == 0 {
+		return true
+	}
+	for _, s := range e.snapshots {
+		if s == snapshotType {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *FuncExporter) Export(app *terra.TerraApp, bl Blacklist, state *ExportState) (ExportOutput, error) {
+	return e.export(app, bl, state)
+}
+
+func (e *FuncExporter) Audit(app *terra.TerraApp, out ExportOutput, audit *Audit) error {
+	for _, res := range out.Checks {
+		res.Stage = audit.stage
+		audit.record(res)
+	}
+	if e.audit == nil {
+		return nil
+	}
+	return e.audit(app, out, audit)
+}
+
+// Export runs e, recording the cw20s it reads in its output.
+func Export(app *terra.TerraApp, e Exporter, bl Blacklist, state *ExportState) (ExportOutput, error) {
+	var out ExportOutput
+	reports, err := CollectCW20(app, func(app *terra.TerraApp) (err error) {
+		out, err = e.Export(app, bl, state)
+		return err
+	})
+	out.CW20Reports = reports
+	return out, err
+}
+
+var exporters = make(map[string]Exporter)
+
+// RegisterExporter adds e to the global registry. It panics on duplicate names.
+func RegisterExporter(e Exporter) {
+	if _, ok := exporters[e.Name()]; ok {
+		panic(fmt.Errorf("exporter %s registered twice", e.Name()))
+	}
+	exporters[e.Name()] = e
+}
+
+// GetExporter returns the registered exporter with the given name.
+func GetExporter(name string) (Exporter, bool) {
+	e, ok := exporters[name]
+	return e, ok
+}
+
+// Exporters returns every registered exporter, sorted by name.
+func Exporters() []Exporter {
+	var names []string
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	all := make([]Exporter, len(names))
+	for i, name := range names {
+		all[i] = exporters[name]
+	}
+	return all
+}
+
+// EnabledExporters returns the registered exporters enabled for a snapshot type, sorted by name.
+func EnabledExporters(snapshotType Snapshot) []Exporter {
+	var names []string
+	for name, e := range exporters {
+		if e.Enabled(snapshotType) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	enabled := make([]Exporter, len(names))
+	for i, name := range names {
+		enabled[i] = exporters[name]
+	}
+	return enabled
+}
@@ -0,0 +1,180 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// MerkleTree is a sorted sha256 merkle tree over airdrop claims, hashed the way
+// cw20-merkle-airdrop verifies them: a leaf is sha256(address+amount) and each
+// parent is the hash of its two children in ascending order. A node without a
+// sibling is carried up to the next layer as is.
+type MerkleTree struct {
+	// layers[0] holds the sorted leaves, the last layer holds the root
+	layers [][][]byte
+	index  map[string]int
+}
+
+// MerkleLeaf hashes a claim of amount by address.
+func MerkleLeaf(address string, amount sdk.Int) []byte {
+	h := sha256.Sum256([]byte(address + amount.String()))
+	return h[:]
+}
+
+func hashPair(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	h := sha256.Sum256(append(append([]byte{}, a...), b...))
+	return h[:]
+}
+
+// NewMerkleTree builds the tree over the claims of balances, by address.
+func NewMerkleTree(balances map[string]sdk.Int) (*MerkleTree, error) {
+	if len(balances) == 0 {
+		return nil, fmt.Errorf("no claims to build a merkle tree from")
+	}
+	leaves := make([][]byte, 0, len(balances))
+	byLeaf := make(map[string]string, len(balances))
+	for addr, amount := range balances {
+		leaf := MerkleLeaf(addr, amount)
+		leaves = append(leaves, leaf)
+		byLeaf[string(leaf)] = addr
+	}
+	sort.Slice(leaves, func(i, j int) bool { return bytes.Compare(leaves[i], leaves[j]) < 0 })
+
+	t := &MerkleTree{layers: [][][]byte{leaves}, index: make(map[string]int, len(leaves))}
+	for i, leaf := range leaves {
+		t.index[byLeaf[string(leaf)]] = i
+	}
+	for layer := leaves; len(layer) > 1; {
+		next := make([][]byte, 0, (len(layer)+1)/2)
+		for i := 0; i < len(layer); i += 2 {
+			if i+1 == len(layer) {
+				next = append(next, layer[i])
+			} else {
+				next = append(next, hashPair(layer[i], layer[i+1]))
+			}
+		}
+		t.layers = append(t.layers, next)
+		layer = next
+	}
+	return t, nil
+}
+
+// Root returns the hex encoded root of the tree.
+func (t *MerkleTree) Root() string {
+	return hex.EncodeToString(t.layers[len(t.layers)-1][0])
+}
+
+// Proof returns the hex encoded siblings on the path from the leaf of address
+// to the root.
+func (t *MerkleTree) Proof(address string) ([]string, error) {
+	i, ok := t.index[address]
+	if !ok {
+		return nil, fmt.Errorf("no claim for %s in the merkle tree", address)
+	}
+	proof := []string{}
+	for _, layer := range t.layers[:len(t.layers)-1] {
+		sibling := i ^ 1
+		if sibling < len(layer) {
+			proof = append(proof, hex.EncodeToString(layer[sibling]))
+		}
+		i /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof checks a claim of amount by address against root, as the
+// airdrop contract does.
+func VerifyMerkleProof(root string, address string, amount sdk.Int, proof []string) error {
+	hash := MerkleLeaf(address, amount)
+	for _, p := range proof {
+		sibling, err := hex.DecodeString(p)
+		if err != nil || len(sibling) != sha256.Size {
+			return fmt.Errorf("invalid proof element %q", p)
+		}
+		hash = hashPair(hash, sibling)
+	}
+	if hex.EncodeToString(hash) != root {
+		return fmt.Errorf("proof of %s %s does not lead to root %s", amount, address, root)
+	}
+	return nil
+}
+
+// MerkleRoot is written for every denom of a merkle airdrop.
+type MerkleRoot struct {
+	Denom       string  `json:"denom"`
+	MerkleRoot  string  `json:"merkle_root"`
+	TotalAmount sdk.Int `json:"total_amount"`
+	Claims      int     `json:"claims"`
+}
+
+// MerkleProof is everything an address needs to claim one denom of an airdrop.
+type MerkleProof struct {
+	Address string   `json:"address"`
+	Denom   string   `json:"denom"`
+	Amount  sdk.Int  `json:"amount"`
+	Proof   []string `json:"proof"`
+}
+
+// WriteMerkleAirdrop builds a merkle tree per denom of the snapshot and writes
+// its root to dir/<denom>/root.json and the proof of every address to
+// dir/<denom>/proofs/<address>.json.
+func WriteMerkleAirdrop(dir string, s SnapshotBalanceAggregateMap) error {
+	claims := make(map[string]map[string]sdk.Int)
+	for _, addr := range s.SortedAddresses() {
+		coins, err := s.CoinsOf(addr)
+		if err != nil {
+			return err
+		}
+		for _, coin := range coins {
+			if claims[coin.Denom] == nil {
+				claims[coin.Denom] = make(map[string]sdk.Int)
+			}
+			claims[coin.Denom][addr] = coin.Amount
+		}
+	}
+
+	for denom, balances := range claims {
+		tree, err := NewMerkleTree(balances)
+		if err != nil {
+			return err
+		}
+		proofDir := filepath.Join(dir, denom, "proofs")
+		if err := os.MkdirAll(proofDir, 0777); err != nil {
+			return err
+		}
+		root := MerkleRoot{Denom: denom, MerkleRoot: tree.Root(), TotalAmount: Sum(balances), Claims: len(balances)}
+		err = WriteWithChecksum(filepath.Join(dir, denom, "root.json"), func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(root)
+		})
+		if err != nil {
+			return err
+		}
+
+		for addr, amount := range balances {
+			proof, err := tree.Proof(addr)
+			if err != nil {
+				return err
+			}
+			out, err := json.Marshal(MerkleProof{Address: addr, Denom: denom, Amount: amount, Proof: proof})
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(proofDir, addr+".json"), out, 0666); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	balances := make(map[string]sdk.Int)
+	for i := 1; i <= 5; i++ {
+		balances[fmt.Sprintf("terra1addr%d", i)] = sdk.NewInt(int64(i * 100))
+	}
+	tree, err := NewMerkleTree(balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, amount := range balances {
+		proof, err := tree.Proof(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMerkleProof(tree.Root(), addr, amount, proof); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMerkleProof(tree.Root(), addr, amount.AddRaw(1), proof); err == nil {
+			t.Fatalf("expected a wrong amount for %s to fail", addr)
+		}
+	}
+
+	// a two leaf root is the hash of both leaves in ascending order
+	a, b := MerkleLeaf("terra1a", sdk.NewInt(1)), MerkleLeaf("terra1b", sdk.NewInt(2))
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	expected := sha256.Sum256(append(append([]byte{}, a...), b...))
+	tree, err = NewMerkleTree(map[string]sdk.Int{"terra1a": sdk.NewInt(1), "terra1b": sdk.NewInt(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Root() != hex.EncodeToString(expected[:]) {
+		t.Fatalf("unexpected root %s", tree.Root())
+	}
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cosmos/cosmos-sdk/x/bank/types"
+)
+
+// WriteCSV writes the snapshot as address,denom,amount lines sorted by address,
+// one address at a time.
+func WriteCSV(w io.Writer, s SnapshotBalanceAggregateMap) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString("address,denom,amount\n"); err != nil {
+		return err
+	}
+	for _, addr := range s.SortedAddresses() {
+		coins, err := s.CoinsOf(addr)
+		if err != nil {
+			return err
+		}
+		for _, coin := range coins {
+			if _, err := fmt.Fprintf(bw, "%s,%s,%s\n", addr, coin.Denom, coin.Amount); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteJSONL writes the snapshot as one bank balance per line sorted by address,
+// one address at a time.
+func WriteJSONL(w io.Writer, s SnapshotBalanceAggregateMap) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, addr := range s.SortedAddresses() {
+		coins, err := s.CoinsOf(addr)
+		if err != nil {
+			return err
+		}
+		if len(coins) == 0 {
+			continue
+		}
+		if err := enc.Encode(types.Balance{Address: addr, Coins: coins}); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteHolders writes the holders of one denom as address,amount lines sorted
+// by address.
+func WriteHolders(w io.Writer, s SnapshotBalanceAggregateMap, denom string) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString("address,amount\n"); err != nil {
+		return err
+	}
+	for _, addr := range s.SortedAddresses() {
+		coins, err := s.CoinsOf(addr)
+		if err != nil {
+			return err
+		}
+		if amount := coins.AmountOf(denom); amount.IsPositive() {
+			if _, err := fmt.Fprintf(bw, "%s,%s\n", addr, amount); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteTokenHolders writes the holders of every tracked token to <token>.csv
+// under dir.
+func WriteTokenHolders(dir string, s SnapshotBalanceAggregateMap) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for _, token := range TrackedTokens() {
+		err := WriteWithChecksum(filepath.Join(dir, token+".csv"), func(w io.Writer) error {
+			return WriteHolders(w, s, token)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteWithChecksum writes a file through write and its sha256 checksum, in
+// the format of sha256sum, to path.sha256.
+func WriteWithChecksum(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if err := write(io.MultiWriter(f, h)); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	sum := fmt.Sprintf("%s  %s\n", hex.EncodeToString(h.Sum(nil)), filepath.Base(path))
+	return os.WriteFile(path+".sha256", []byte(sum), 0666)
+}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestWriteSortedOutputs(t *testing.T) {
+	s := SnapshotBalanceAggregateMap{
+		"addr2": {
+			{Denom: DenomUST, Balance: sdk.NewInt(5)},
+			{Denom: DenomLUNA, Balance: sdk.NewInt(1)},
+			{Denom: DenomUST, Balance: sdk.NewInt(5)},
+		},
+		"addr1": {
+			{Denom: DenomLUNA, Balance: sdk.NewInt(7)},
+			{Denom: DenomAUST, Balance: sdk.ZeroInt()},
+		},
+	}
+
+	var csv bytes.Buffer
+	if err := WriteCSV(&csv, s); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"address,denom,amount",
+		"addr1," + DenomLUNA + ",7",
+		"addr2," + DenomLUNA + ",1",
+		"addr2," + DenomUST + ",10",
+		"",
+	}, "\n")
+	if csv.String() != expected {
+		t.Fatalf("unexpected csv:\n%s", csv.String())
+	}
+
+	var jsonl bytes.Buffer
+	if err := WriteJSONL(&jsonl, s); err != nil {
+		t.Fatal(err)
+	}
+	expected = strings.Join([]string{
+		`{"address":"addr1","coins":[{"denom":"` + DenomLUNA + `","amount":"7"}]}`,
+		`{"address":"addr2","coins":[{"denom":"` + DenomLUNA + `","amount":"1"},{"denom":"` + DenomUST + `","amount":"10"}]}`,
+		"",
+	}, "\n")
+	if jsonl.String() != expected {
+		t.Fatalf("unexpected jsonl:\n%s", jsonl.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "balances.csv")
+	if err := WriteWithChecksum(path, func(w io.Writer) error { return WriteCSV(w, s) }); err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != csv.String() {
+		t.Fatalf("unexpected file contents:\n%s", written)
+	}
+	sum, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.Sum256(written)
+	if string(sum) != hex.EncodeToString(h[:])+"  balances.csv\n" {
+		t.Fatalf("unexpected checksum file %q", sum)
+	}
+
+	s["addr3"] = []SnapshotBalance{{Denom: DenomLUNA}}
+	if err := WriteCSV(io.Discard, s); err == nil {
+		t.Fatal("expected a nil balance to be rejected")
+	}
+}
@@ -0,0 +1,164 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Resource names something a pipeline stage consumes or produces.
+type Resource string
+
+const (
+	// ResourceCompoundedLps is produced by every lp-compounder exporter.
+	ResourceCompoundedLps Resource = "lp-compounder map"
+	// ResourceMergedSnapshot is the snapshot all protocol exports are merged into.
+	ResourceMergedSnapshot Resource = "merged snapshot"
+)
+
+func SnapshotOf(exporter string) Resource {
+	return Resource(fmt.Sprintf("%s snapshot", exporter))
+}
+
+func LpHoldingsOf(exporter string) Resource {
+	return Resource(fmt.Sprintf("%s lp holdings", exporter))
+}
+
+func DenomConversion(from string, to string) Resource {
+	return Resource(fmt.Sprintf("denom %s → %s", from, to))
+}
+
+// ConversionOf returns the denoms of a resource named by DenomConversion.
+func ConversionOf(r Resource) (from string, to string, ok bool) {
+	parts := strings.Fields(string(r))
+	if len(parts) != 4 || parts[0] != "denom" || parts[2] != "→" {
+		return "", "", false
+	}
+	return parts[1], parts[3], true
+}
+
+func DenomHolders(denom string) Resource {
+	return Resource(fmt.Sprintf("%s holders", denom))
+}
+
+// PlanStep is a stage of the export pipeline along with the stages it waits on.
+type PlanStep struct {
+	Exporter  Exporter
+	DependsOn []string
+}
+
+// Plan is the resolved export pipeline, in an order where every step comes after
+// the steps producing its inputs.
+type Plan struct {
+	SnapshotType Snapshot
+	Steps        []PlanStep
+}
+
+// BuildPlan links stages by their declared inputs and outputs. A stage depends on
+// every producer of each of its inputs. It fails on inputs nobody produces and on
+// cycles. Independent stages are ordered by name so the plan is deterministic.
+func BuildPlan(snapshotType Snapshot, stages []Exporter) (*Plan, error) {
+	byName := make(map[string]Exporter)
+	producers := make(map[Resource][]string)
+	for _, s := range stages {
+		if _, ok := byName[s.Name()]; ok {
+			return nil, fmt.Errorf("stage %s declared twice", s.Name())
+		}
+		byName[s.Name()] = s
+		for _, r := range s.Outputs() {
+			producers[r] = append(producers[r], s.Name())
+		}
+	}
+
+	deps := make(map[string][]string)
+	dependents := make(map[string][]string)
+	for _, s := range stages {
+		seen := make(map[string]bool)
+		for _, r := range s.Inputs() {
+			ps, ok := producers[r]
+			if !ok {
+				return nil, fmt.Errorf("stage %s consumes %q, which no stage produces", s.Name(), r)
+			}
+			for _, p := range ps {
+				if p == s.Name() || seen[p] {
+					continue
+				}
+				seen[p] = true
+				deps[s.Name()] = append(deps[s.Name()], p)
+				dependents[p] = append(dependents[p], s.Name())
+			}
+		}
+		sort.Strings(deps[s.Name()])
+	}
+
+	// Kahn's algorithm, always picking the first ready stage by name
+	remaining := make(map[string]int)
+	var ready []string
+	for _, s := range stages {
+		remaining[s.Name()] = len(deps[s.Name()])
+		if remaining[s.Name()] == 0 {
+			ready = append(ready, s.Name())
+		}
+	}
+	plan := &Plan{SnapshotType: snapshotType}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		plan.Steps = append(plan.Steps, PlanStep{
+			Exporter:  byName[name],
+			DependsOn: deps[name],
+		})
+		for _, d := range dependents[name] {
+			remaining[d]--
+			if remaining[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(plan.Steps) != len(stages) {
+		var cyclic []string
+		for name, n := range remaining {
+			if n > 0 {
+				cyclic = append(cyclic, name)
+			}
+		}
+		sort.Strings(cyclic)
+		return nil, fmt.Errorf("dependency cycle between stages: %s", strings.Join(cyclic, ", "))
+	}
+	return plan, nil
+}
+
+// Print writes a human readable description of the plan.
+func (p *Plan) Print(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "Export plan (%s), %d stages\n", p.SnapshotType, len(p.Steps)); err != nil {
+		return err
+	}
+	for i, step := range p.Steps {
+		e := step.Exporter
+		lines := []string{fmt.Sprintf("%3d. %s [%s]", i+1, e.Name(), e.Kind())}
+		if len(step.DependsOn) > 0 {
+			lines = append(lines, fmt.Sprintf("       after:    %s", strings.Join(step.DependsOn, ", ")))
+		}
+		if len(e.Inputs()) > 0 {
+			lines = append(lines, fmt.Sprintf("       consumes: %s", joinResources(e.Inputs())))
+		}
+		if len(e.Outputs()) > 0 {
+			lines = append(lines, fmt.Sprintf("       produces: %s", joinResources(e.Outputs())))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinResources(rs []Resource) string {
+	s := make([]string, len(rs))
+	for i, r := range rs {
+		s[i] = string(r)
+	}
+	return strings.Join(s, ", ")
+}
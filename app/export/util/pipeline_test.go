@@ -0,0 +1,59 @@
+package util
+
+import (
+	"testing"
+
+	terra "github.com/terra-money/core/app"
+)
+
+func testStage(name string, inputs []Resource, outputs []Resource) Exporter {
+	return NewExporter(name, KindMerge, func(*terra.TerraApp, Blacklist, *ExportState) (ExportOutput, error) {
+		return ExportOutput{}, nil
+	}).Consumes(inputs...).Produces(outputs...)
+}
+
+func TestBuildPlanOrdersByResources(t *testing.T) {
+	plan, err := BuildPlan(Snapshot(PostAttack), []Exporter{
+		testStage("c", []Resource{"b-out"}, nil),
+		testStage("b", []Resource{"a-out"}, []Resource{"b-out"}),
+		testStage("a", nil, []Resource{"a-out"}),
+		testStage("a2", nil, []Resource{"a-out"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, step := range plan.Steps {
+		names = append(names, step.Exporter.Name())
+	}
+	expected := []string{"a", "a2", "b", "c"}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, names)
+		}
+	}
+	if len(plan.Steps[2].DependsOn) != 2 {
+		t.Fatalf("b should wait on every producer of a-out, got %v", plan.Steps[2].DependsOn)
+	}
+}
+
+func TestBuildPlanMissingProducer(t *testing.T) {
+	_, err := BuildPlan(Snapshot(PostAttack), []Exporter{
+		testStage("a", []Resource{"nothing"}, nil),
+	})
+	if err == nil {
+		t.Fatal("expected missing producer error")
+	}
+}
+
+func TestBuildPlanCycle(t *testing.T) {
+	_, err := BuildPlan(Snapshot(PostAttack), []Exporter{
+		testStage("a", []Resource{"b-out"}, []Resource{"a-out"}),
+		testStage("b", []Resource{"a-out"}, []Resource{"b-out"}),
+		testStage("c", nil, nil),
+	})
+	if err == nil {
+		t.Fatal("expected cycle error")
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// provenance makes balances carry the sources they were derived from.
+// It is off by default as it grows every snapshot considerably.
+var provenance bool
+
+func EnableProvenance() {
+	provenance = true
+}
+
+func ProvenanceEnabled() bool {
+	return provenance
+}
+
+// Source records one contribution to a balance: Amount of Denom found by
+// Exporter, multiplied by Rate to get its share of the balance.
+type Source struct {
+	Exporter string  `json:"exporter"`
+	Contract string  `json:"contract,omitempty"`
+	Denom    string  `json:"denom"`
+	Amount   sdk.Int `json:"amount"`
+	Rate     sdk.Dec `json:"rate"`
+	// Via lists the contracts that converted or passed on the amount, in order
+	Via []string `json:"via,omitempty"`
+}
+
+// WithSource returns b with src added, if provenance is enabled.
+func (b SnapshotBalance) WithSource(src Source) SnapshotBalance {
+	if !provenance {
+		return b
+	}
+	if src.Rate.IsNil() {
+		src.Rate = sdk.OneDec()
+	}
+	b.Sources = append(append([]Source{}, b.Sources...), src)
+	return b
+}
+
+// Convert returns b converted into denom at rate by contract, keeping its sources.
+func (b SnapshotBalance) Convert(denom string, rate sdk.Dec, contract string) SnapshotBalance {
+	converted := SnapshotBalance{
+		Denom:   denom,
+		Balance: rate.MulInt(b.Balance).TruncateInt(),
+	}
+	for _, src := range b.Sources {
+		src.Rate = src.Rate.Mul(rate)
+		src.Via = append(append([]string{}, src.Via...), contract)
+		converted.Sources = append(converted.Sources, src)
+	}
+	return converted
+}
+
+// Through returns b with contract appended to the path of every source.
+func (b SnapshotBalance) Through(contract string) SnapshotBalance {
+	passed := SnapshotBalance{Denom: b.Denom, Balance: b.Balance}
+	for _, src := range b.Sources {
+		src.Via = append(append([]string{}, src.Via...), contract)
+		passed.Sources = append(passed.Sources, src)
+	}
+	return passed
+}
+
+// Attribute records exporter as the source of every balance without sources.
+func (s SnapshotBalanceAggregateMap) Attribute(exporter string) {
+	if !provenance {
+		return
+	}
+	for _, sbs := range s {
+		for i, sb := range sbs {
+			if len(sb.Sources) > 0 || sb.Balance.IsNil() {
+				continue
+			}
+			sbs[i].Sources = []Source{{
+				Exporter: exporter,
+				Denom:    sb.Denom,
+				Amount:   sb.Balance,
+				Rate:     sdk.OneDec(),
+			}}
+		}
+	}
+}
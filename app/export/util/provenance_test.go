@@ -0,0 +1,41 @@
+package util
+
+import (
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestSourcesSurviveMergeAndConversion(t *testing.T) {
+	EnableProvenance()
+	defer func() { provenance = false }()
+
+	a := SnapshotBalanceAggregateMap{"terra1user": {{Denom: DenomSTLUNA, Balance: sdk.NewInt(100)}}}
+	a.Attribute("lido")
+	b := SnapshotBalanceAggregateMap{"terra1user": {{Denom: DenomSTLUNA, Balance: sdk.NewInt(50)}}}
+	b.Attribute("astroport")
+
+	merged := MergeSnapshots(a, b)
+	sb := merged["terra1user"][0]
+	if len(sb.Sources) != 2 {
+		t.Fatalf("expected 2 sources after merge, got %d", len(sb.Sources))
+	}
+
+	converted := sb.Convert(DenomLUNA, sdk.NewDecWithPrec(2, 0), "terra1hub")
+	if !converted.Balance.Equal(sdk.NewInt(300)) {
+		t.Fatalf("unexpected converted balance %s", converted.Balance)
+	}
+	total := sdk.ZeroInt()
+	for _, src := range converted.Sources {
+		if src.Denom != DenomSTLUNA || len(src.Via) != 1 || src.Via[0] != "terra1hub" {
+			t.Fatalf("unexpected source %+v", src)
+		}
+		total = total.Add(src.Rate.MulInt(src.Amount).TruncateInt())
+	}
+	if !total.Equal(converted.Balance) {
+		t.Fatalf("sources add up to %s, balance is %s", total, converted.Balance)
+	}
+	if len(sb.Sources[0].Via) != 0 {
+		t.Fatal("conversion modified the original sources")
+	}
+}
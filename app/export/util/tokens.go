@@ -0,0 +1,62 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+var (
+	trackedTokensMtx sync.RWMutex
+	// cw20 contracts snapshotted as denoms of their own, named after their address
+	trackedTokens = make(map[string]bool)
+)
+
+// ValidateToken fails unless addr is a cw20 that can be tracked, i.e. a valid
+// address not already snapshotted under a denom of its own.
+func ValidateToken(addr string) error {
+	if _, err := sdk.AccAddressFromBech32(addr); err != nil {
+		return fmt.Errorf("invalid token %s: %v", addr, err)
+	}
+	if a, ok := assetsByID[addr]; ok {
+		return fmt.Errorf("token %s is already snapshotted as %s", addr, a.Denom)
+	}
+	return nil
+}
+
+// TrackTokens replaces the tracked tokens. Every DEX pool holding one of them
+// is decomposed and its holders are snapshotted under the token address.
+func TrackTokens(addrs ...string) error {
+	tokens := make(map[string]bool)
+	for _, addr := range addrs {
+		if err := ValidateToken(addr); err != nil {
+			return err
+		}
+		tokens[addr] = true
+	}
+	trackedTokensMtx.Lock()
+	defer trackedTokensMtx.Unlock()
+	trackedTokens = tokens
+	return nil
+}
+
+// TrackedTokens returns the tracked token addresses, sorted.
+func TrackedTokens() []string {
+	trackedTokensMtx.RLock()
+	defer trackedTokensMtx.RUnlock()
+	tokens := make([]string, 0, len(trackedTokens))
+	for addr := range trackedTokens {
+		tokens = append(tokens, addr)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// IsTrackedToken reports whether addr is a tracked token, and so a snapshot denom.
+func IsTrackedToken(addr string) bool {
+	trackedTokensMtx.RLock()
+	defer trackedTokensMtx.RUnlock()
+	return trackedTokens[addr]
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestTrackTokens(t *testing.T) {
+	mir := sdk.AccAddress(bytes.Repeat([]byte{1}, 20)).String()
+	if err := TrackTokens(AddressAUST); err == nil {
+		t.Fatal("expected aUST to be rejected, it has a denom of its own")
+	}
+	if err := TrackTokens("mir"); err == nil {
+		t.Fatal("expected an invalid address to be rejected")
+	}
+	if err := TrackTokens(mir); err != nil {
+		t.Fatal(err)
+	}
+	defer TrackTokens()
+
+	bl := Blacklist{}
+	RegisterPool(bl, "pair")
+	if !IsTrackedToken(mir) || len(bl.GetAddressesByDenom(mir)) != 1 {
+		t.Fatalf("expected %s to be tracked and the pair blacklisted, got %v", mir, bl)
+	}
+
+	s := SnapshotBalanceAggregateMap{
+		"addr2": {{Denom: mir, Balance: sdk.NewInt(3)}, {Denom: DenomUST, Balance: sdk.NewInt(5)}},
+		"addr1": {{Denom: mir, Balance: sdk.NewInt(7)}},
+		"addr3": {{Denom: DenomLUNA, Balance: sdk.NewInt(1)}},
+	}
+	var holders bytes.Buffer
+	if err := WriteHolders(&holders, s, mir); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "address,amount\naddr1,7\naddr2,3\n"; holders.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, holders.String())
+	}
+}
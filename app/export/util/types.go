@@ -1,6 +1,10 @@
 package util
 
 import (
+	"fmt"
+	"sort"
+	"sync"
+
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/bank/types"
 )
@@ -9,6 +13,8 @@ type BalanceMap map[string]sdk.Int
 type SnapshotBalance struct {
 	Denom   string  `json:"denom"`
 	Balance sdk.Int `json:"balance"`
+	// Sources is only populated when provenance is enabled
+	Sources []Source `json:"sources,omitempty"`
 }
 
 func (b *SnapshotBalance) AddInto(i sdk.Int) {
@@ -21,12 +27,52 @@ type SnapshotBalanceAggregateMap map[string][]SnapshotBalance
 // map[denom][]address
 type Blacklist map[string][]string
 
+// blacklistMtx guards every Blacklist, as exporters register addresses concurrently
+var blacklistMtx sync.RWMutex
+
 func (bl Blacklist) RegisterAddress(denom string, address string) {
+	blacklistMtx.Lock()
+	defer blacklistMtx.Unlock()
 	bl[denom] = append(bl[denom], address)
 }
 
 func (bl Blacklist) GetAddressesByDenom(denom string) []string {
-	return bl[denom]
+	blacklistMtx.RLock()
+	defer blacklistMtx.RUnlock()
+	return append([]string{}, bl[denom]...)
+}
+
+// Copy returns an independent copy of the blacklist.
+func (bl Blacklist) Copy() Blacklist {
+	blacklistMtx.RLock()
+	defer blacklistMtx.RUnlock()
+	c := make(Blacklist, len(bl))
+	for denom, addrs := range bl {
+		c[denom] = append([]string{}, addrs...)
+	}
+	return c
+}
+
+// Since returns the addresses registered in bl after base was copied from it.
+func (bl Blacklist) Since(base Blacklist) Blacklist {
+	blacklistMtx.RLock()
+	defer blacklistMtx.RUnlock()
+	delta := make(Blacklist)
+	for denom, addrs := range bl {
+		if len(addrs) > len(base[denom]) {
+			delta[denom] = append([]string{}, addrs[len(base[denom]):]...)
+		}
+	}
+	return delta
+}
+
+// Register adds every address of delta to bl.
+func (bl Blacklist) Register(delta Blacklist) {
+	for denom, addrs := range delta {
+		for _, addr := range addrs {
+			bl.RegisterAddress(denom, addr)
+		}
+	}
 }
 
 func MergeSnapshots(ss ...SnapshotBalanceAggregateMap) (s3 SnapshotBalanceAggregateMap) {
@@ -50,6 +96,9 @@ func (s SnapshotBalanceAggregateMap) AppendOrAddBalance(addr string, newBalance
 				} else {
 					s[addr][i].Balance = s[addr][i].Balance.Add(newBalance.Balance)
 				}
+				if len(newBalance.Sources) > 0 {
+					s[addr][i].Sources = append(append([]Source{}, s[addr][i].Sources...), newBalance.Sources...)
+				}
 				return
 			}
 		}
@@ -68,7 +117,7 @@ func (s SnapshotBalanceAggregateMap) GetAddrBalance(addr string, denom string) s
 }
 
 func (bl Blacklist) GetAddressesByDenomMap(denom string) map[string]bool {
-	list := bl[denom]
+	list := bl.GetAddressesByDenom(denom)
 
 	m := make(map[string]bool)
 	for _, addr := range list {
@@ -130,6 +179,8 @@ func (s SnapshotBalanceAggregateMap) PickDenomIntoBalanceMap(denom string) Balan
 }
 
 func (s SnapshotBalanceAggregateMap) ApplyBlackList(bl Blacklist) {
+	blacklistMtx.RLock()
+	defer blacklistMtx.RUnlock()
 	for denom, addrList := range bl {
 		for _, addr := range addrList {
 			for i, snapshotBalance := range s[addr] {
@@ -139,6 +190,7 @@ func (s SnapshotBalanceAggregateMap) ApplyBlackList(bl Blacklist) {
 					s[addr][i] = SnapshotBalance{
 						Denom:   snapshotBalance.Denom,
 						Balance: sdk.NewInt(0),
+						Sources: snapshotBalance.Sources,
 					}
 				}
 			}
@@ -146,23 +198,58 @@ func (s SnapshotBalanceAggregateMap) ApplyBlackList(bl Blacklist) {
 	}
 }
 
-func (s SnapshotBalanceAggregateMap) ExportToBalances() []types.Balance {
-	// merge just to make sure all balances are collapsed
-	merged := MergeSnapshots(s)
-
+// ExportToBalances returns the snapshot as bank balances in canonical order:
+// addresses sorted, coins merged and sorted by denom, zero coins and addresses
+// left without coins dropped. A nil balance is an error.
+func (s SnapshotBalanceAggregateMap) ExportToBalances() ([]types.Balance, error) {
 	var export []types.Balance
-	for addr, balances := range merged {
-		account := types.Balance{
-			Address: addr,
+	for _, addr := range s.SortedAddresses() {
+		coins, err := s.CoinsOf(addr)
+		if err != nil {
+			return nil, err
 		}
-		for _, balance := range balances {
-			account.Coins = append(account.Coins, sdk.Coin{
-				Amount: balance.Balance,
-				Denom:  balance.Denom,
-			})
+		if len(coins) == 0 {
+			continue
 		}
+		export = append(export, types.Balance{
+			Address: addr,
+			Coins:   coins,
+		})
+	}
+	return export, nil
+}
 
-		export = append(export, account)
+// SortedAddresses returns the addresses of the snapshot in ascending order.
+func (s SnapshotBalanceAggregateMap) SortedAddresses() []string {
+	addrs := make([]string, 0, len(s))
+	for addr := range s {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// CoinsOf returns the balances of addr merged by denom and sorted by denom,
+// leaving out zero coins. A nil balance is an error.
+func (s SnapshotBalanceAggregateMap) CoinsOf(addr string) (sdk.Coins, error) {
+	amounts := make(map[string]sdk.Int)
+	for _, b := range s[addr] {
+		if b.Balance.IsNil() {
+			return nil, fmt.Errorf("nil %s balance for %s", b.Denom, addr)
+		}
+		if amount, ok := amounts[b.Denom]; ok {
+			amounts[b.Denom] = amount.Add(b.Balance)
+		} else {
+			amounts[b.Denom] = b.Balance
+		}
+	}
+	var coins sdk.Coins
+	for denom, amount := range amounts {
+		if amount.IsZero() {
+			continue
+		}
+		coins = append(coins, sdk.Coin{Denom: denom, Amount: amount})
 	}
-	return export
+	sort.Slice(coins, func(i, j int) bool { return coins[i].Denom < coins[j].Denom })
+	return coins, nil
 }
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"encoding/json"
 	"testing"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -97,3 +98,44 @@ func TestMergeSnapshotMultipleBalances(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestExportToBalancesIsCanonical(t *testing.T) {
+	s1 := SnapshotBalanceAggregateMap{
+		"addr2": {
+			{Denom: DenomUST, Balance: sdk.NewInt(1)},
+			{Denom: DenomLUNA, Balance: sdk.NewInt(2)},
+			{Denom: DenomUST, Balance: sdk.NewInt(3)},
+		},
+		"addr1": {{Denom: DenomAUST, Balance: sdk.NewInt(4)}},
+		"addr3": {{Denom: DenomAUST, Balance: sdk.ZeroInt()}},
+	}
+	s2 := SnapshotBalanceAggregateMap{
+		"addr1": {{Denom: DenomAUST, Balance: sdk.NewInt(4)}},
+		"addr2": {
+			{Denom: DenomLUNA, Balance: sdk.NewInt(2)},
+			{Denom: DenomUST, Balance: sdk.NewInt(4)},
+		},
+	}
+
+	b1, err := s1.ExportToBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := s2.ExportToBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	j1, _ := json.Marshal(b1)
+	j2, _ := json.Marshal(b2)
+	if string(j1) != string(j2) {
+		t.Fatalf("expected identical exports, got\n%s\n%s", j1, j2)
+	}
+	if len(b1) != 2 || b1[0].Address != "addr1" || b1[1].Coins[0].Denom != DenomLUNA {
+		t.Fatalf("unexpected order %s", j1)
+	}
+
+	s1["addr4"] = []SnapshotBalance{{Denom: DenomUST}}
+	if _, err := s1.ExportToBalances(); err == nil {
+		t.Fatal("expected a nil balance to be rejected")
+	}
+}
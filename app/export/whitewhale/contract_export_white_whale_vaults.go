@@ -23,23 +23,14 @@ type pool struct {
 }
 
 type pair struct {
-	AssetInfos     [2]assetInfo `json:"asset_infos"`
-	ContractAddr   []byte       `json:"contract_addr"`
-	LiquidityToken string       `json:"liquidity_token"`
-}
-
-type assetInfo struct {
-	Token *struct {
-		ContractAddr string `json:"contract_addr"`
-	} `json:"token,omitempty"`
-	NativeToken *struct {
-		Denom string `json:"denom"`
-	} `json:"native_token,omitempty"`
+	AssetInfos     [2]util.AssetInfo `json:"asset_infos"`
+	ContractAddr   []byte            `json:"contract_addr"`
+	LiquidityToken string            `json:"liquidity_token"`
 }
 
 type asset struct {
-	AssetInfo assetInfo `json:"info"`
-	Amount    sdk.Int   `json:"amount"`
+	AssetInfo util.AssetInfo `json:"info"`
+	Amount    sdk.Int        `json:"amount"`
 }
 
 func ExportWhiteWhaleVaults(app *terra.TerraApp, bl util.Blacklist) (util.SnapshotBalanceAggregateMap, error) {
@@ -95,7 +86,12 @@ func ExportWhiteWhaleVaults(app *terra.TerraApp, bl util.Blacklist) (util.Snapsh
 	return snapshot, nil
 }
 
-func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error {
+var (
+	auditUST  = util.NewInvariant("uusd in vault", util.SeverityError, 10000)
+	auditAUST = util.NewInvariant("aUST in vault", util.SeverityError, 10000)
+)
+
+func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap, audit *util.Audit) error {
 	ctx := util.PrepCtx(app)
 	q := util.PrepWasmQueryServer(app)
 	aUstBalance, err := util.GetCW20Balance(ctx, q, util.AUST, whiteWhaleVault)
@@ -107,14 +103,8 @@ func Audit(app *terra.TerraApp, snapshot util.SnapshotBalanceAggregateMap) error
 		return err
 	}
 
-	err = util.AlmostEqual("whitewhale ust", ustBalance, snapshot.SumOfDenom(util.DenomUST), sdk.NewInt(10000))
-	if err != nil {
-		return err
-	}
-	err = util.AlmostEqual("whitewhale aust", aUstBalance, snapshot.SumOfDenom(util.DenomAUST), sdk.NewInt(10000))
-	if err != nil {
-		return err
-	}
+	audit.Check(auditUST, ustBalance, snapshot.SumOfDenom(util.DenomUST))
+	audit.Check(auditAUST, aUstBalance, snapshot.SumOfDenom(util.DenomAUST))
 
 	ci, err := app.WasmKeeper.GetContractInfo(sdk.UnwrapSDKContext(ctx), util.ToAddress(whiteWhaleVault))
 	if err != nil {
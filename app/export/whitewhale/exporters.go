@@ -0,0 +1,9 @@
+package whitewhale
+
+import (
+	"github.com/terra-money/core/app/export/util"
+)
+
+func init() {
+	util.RegisterExporter(util.NewSBAExporter("whitewhale", ExportWhiteWhaleVaults, Audit).WithVersion(2))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cosmos/cosmos-sdk/server"
+
+	export "github.com/terra-money/core/app/export"
+	"github.com/terra-money/core/app/export/util"
+)
+
+// cacheCmd inspects the exporter cache written by the export command.
+func cacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect the contract export cache",
+	}
+	cmd.PersistentFlags().Int64(server.FlagHeight, 0, "Height of the cache to inspect")
+	_ = cmd.MarkPersistentFlagRequired(server.FlagHeight)
+
+	verify := &cobra.Command{
+		Use:   "verify",
+		Short: "Check cached exporter outputs against exporter versions, file contents and upstream inputs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			height, _ := cmd.Flags().GetInt64(server.FlagHeight)
+			profile, err := profileFromFlags(cmd, height)
+			if err != nil {
+				return err
+			}
+			provenance, _ := cmd.Flags().GetBool(flagProvenance)
+			cache, err := export.OpenCache(profile, provenance, nil, false)
+			if err != nil {
+				return err
+			}
+			plan, err := export.BuildExportPlan(profile, export.ProtocolFilter{})
+			if err != nil {
+				return err
+			}
+
+			invalid := 0
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "EXPORTER\tSTATUS\tDETAIL")
+			for _, status := range cache.Verify(plan) {
+				if status.State != util.CacheOK {
+					invalid++
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", status.Exporter, status.State, status.Detail)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if invalid > 0 {
+				return fmt.Errorf("%d cache entries are not valid", invalid)
+			}
+			return nil
+		},
+	}
+	verify.Flags().String(flagProfile, "", "Profile the export ran with (a built-in profile for the height by default)")
+	verify.Flags().Bool(flagProvenance, false, "Whether the export ran with --provenance")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "ls",
+			Short: "List cached exporter outputs and flag entries from outdated exporter versions",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				height, _ := cmd.Flags().GetInt64(server.FlagHeight)
+				cache, err := util.OpenCache(height, nil, false)
+				if err != nil {
+					return err
+				}
+
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "EXPORTER\tVERSION\tSTATUS\tCREATED\tFILE")
+				for _, entry := range cache.Entries() {
+					status := string(util.CacheOK)
+					if e, ok := util.GetExporter(entry.Exporter); !ok {
+						status = string(util.CacheOrphan)
+					} else if e.Version() != entry.Version {
+						status = fmt.Sprintf("%s (v%d)", util.CacheStale, e.Version())
+					}
+					fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", entry.Exporter, entry.Version, status, entry.CreatedAt.Format(time.RFC3339), entry.File)
+				}
+				return w.Flush()
+			},
+		},
+		verify,
+	)
+	return cmd
+}
+
+// profileFromFlags returns the profile named by --profile, which must be for
+// height, or the built-in profile of height.
+func profileFromFlags(cmd *cobra.Command, height int64) (export.Profile, error) {
+	path, _ := cmd.Flags().GetString(flagProfile)
+	if path == "" {
+		return export.ProfileFor(height), nil
+	}
+	profile, err := export.LoadProfile(path)
+	if err != nil {
+		return export.Profile{}, err
+	}
+	if profile.Height != height {
+		return export.Profile{}, fmt.Errorf("profile is for height %d, not %d", profile.Height, height)
+	}
+	return profile, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	export "github.com/terra-money/core/app/export"
+)
+
+const (
+	flagThreshold = "threshold"
+	flagOutput    = "output"
+)
+
+func diffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [before] [after]",
+		Short: "Compare the balances of two snapshots",
+		Long: `Compare the balances of two snapshots, each a genesis, a JSON list of bank
+balances, a csv or jsonl snapshot output, or a snapshot or cache entry saved in
+./cache-<height>. Changes are counted per denom as added, removed, increased or
+decreased, and totals are grouped by exporter when the snapshots were exported
+with --provenance.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			threshold, _ := cmd.Flags().GetString(flagThreshold)
+			min, ok := sdk.NewIntFromString(threshold)
+			if !ok || min.IsNegative() {
+				return fmt.Errorf("invalid --%s %q", flagThreshold, threshold)
+			}
+			before, err := export.LoadSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+			after, err := export.LoadSnapshot(args[1])
+			if err != nil {
+				return err
+			}
+			diff, err := export.DiffSnapshots(before, after, min)
+			if err != nil {
+				return err
+			}
+
+			if output, _ := cmd.Flags().GetString(flagOutput); output != "" {
+				report, err := json.MarshalIndent(diff, "", "  ")
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(output, report, 0666); err != nil {
+					return err
+				}
+				cmd.PrintErrf("wrote %s\n", output)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintln(w, "DENOM\tBEFORE\tAFTER\tADDED\tREMOVED\tINCREASED\tDECREASED\t")
+			for _, d := range diff.Denoms {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d (+%s)\t%d (-%s)\t%d (+%s)\t%d (-%s)\t\n", d.Denom, d.Before, d.After,
+					d.Added, d.AddedAmount, d.Removed, d.RemovedAmount,
+					d.Increased, d.IncreasedAmount, d.Decreased, d.DecreasedAmount)
+			}
+			if len(diff.Exporters) > 0 {
+				fmt.Fprintln(w, "\t\t\t\t\t\t\t")
+				fmt.Fprintln(w, "EXPORTER\tDENOM\tBEFORE\tAFTER\tDELTA\t\t\t")
+				for _, e := range diff.Exporters {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\t\t\n", e.Exporter, e.Denom, e.Before, e.After, e.Delta)
+				}
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().String(flagThreshold, "0", "Only report address changes larger than this amount")
+	cmd.Flags().String(flagOutput, "snapshot-diff.json", "File the JSON report is written to, none if empty")
+	return cmd
+}
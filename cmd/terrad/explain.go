@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cosmos/cosmos-sdk/server"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	export "github.com/terra-money/core/app/export"
+)
+
+func explainCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain [address]",
+		Short: "Show stage by stage how an address got its final balances",
+		Long: `Show stage by stage how an address got its final balances, from the cached
+exporter outputs and the intermediate snapshots in ./cache-<height>.
+Per balance sources are only shown when the export ran with --provenance.
+Pass the --profile, --tokens and --provenance the export ran with; cached
+outputs of other settings are not used.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			height, _ := cmd.Flags().GetInt64(server.FlagHeight)
+			if height < 0 {
+				return fmt.Errorf("--%s is required", server.FlagHeight)
+			}
+			profile, err := profileFromFlags(cmd, height)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed(flagTokens) {
+				profile.Tokens, _ = cmd.Flags().GetStringSlice(flagTokens)
+			}
+			provenance, _ := cmd.Flags().GetBool(flagProvenance)
+			ex, err := export.ExplainAddress(profile, provenance, args[0])
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "%s @ %d\n\nContributions\n", ex.Address, height)
+			printSteps(w, ex.Contributions)
+			fmt.Fprintln(w, "\nStages")
+			printSteps(w, ex.Stages)
+
+			fmt.Fprintln(w, "\nFinal balances")
+			if len(ex.Final) == 0 {
+				fmt.Fprintln(w, "  none")
+			}
+			for _, b := range ex.Final {
+				fmt.Fprintf(w, "  %s %s\n", b.Balance, b.Denom)
+				if len(b.Sources) == 0 {
+					continue
+				}
+				fmt.Fprintln(w, "    EXPORTER\tCONTRACT\tAMOUNT\tRATE\tSHARE\tVIA")
+				for _, src := range b.Sources {
+					share := src.Rate.MulInt(src.Amount).TruncateInt()
+					fmt.Fprintf(w, "    %s\t%s\t%s %s\t%s\t%s\t%s\n",
+						src.Exporter, orDash(src.Contract), src.Amount, src.Denom,
+						src.Rate, share, orDash(strings.Join(src.Via, " → ")))
+				}
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().String(flagProfile, "", "Profile the export ran with (a built-in profile for the height by default)")
+	cmd.Flags().StringSlice(flagTokens, nil, "cw20 tokens the export tracked (overrides the profile)")
+	cmd.Flags().Bool(flagProvenance, false, "Whether the export ran with --provenance")
+	return cmd
+}
+
+func printSteps(w io.Writer, steps []export.ExplainStep) {
+	if len(steps) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, step := range steps {
+		fmt.Fprintf(w, "  %s\t[%s]\t%s\n", step.Stage, orDash(string(step.Kind)), orDash(formatBalances(step.Balances)))
+		for _, note := range step.Notes {
+			fmt.Fprintf(w, "\t\t  %s\n", note)
+		}
+	}
+}
+
+func formatBalances(balances map[string]sdk.Int) string {
+	var s []string
+	for denom, amount := range balances {
+		s = append(s, fmt.Sprintf("%s %s", amount, denom))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
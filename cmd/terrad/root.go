@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"io"
 	"os"
@@ -30,11 +29,8 @@ import (
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	"github.com/cosmos/cosmos-sdk/x/crisis"
 	genutilcli "github.com/cosmos/cosmos-sdk/x/genutil/client/cli"
-	"github.com/cosmos/cosmos-sdk/x/staking"
 
-	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
 	terraapp "github.com/terra-money/core/app"
-	export "github.com/terra-money/core/app/export"
 	terralegacy "github.com/terra-money/core/app/legacy"
 	"github.com/terra-money/core/app/params"
 	authcustomcli "github.com/terra-money/core/custom/auth/client/cli"
@@ -113,10 +109,12 @@ func initRootCmd(rootCmd *cobra.Command, encodingConfig params.EncodingConfig) {
 		tmcli.NewCompletionCmd(rootCmd, true),
 		testnetCmd(terraapp.ModuleBasics, banktypes.GenesisBalancesIterator{}),
 		debug.Cmd(),
+		cacheCmd(),
 	)
 
 	a := appCreator{encodingConfig}
 	server.AddCommands(rootCmd, terraapp.DefaultNodeHome, a.newApp, a.appExport, addModuleInitFlags)
+	rootCmd.AddCommand(a.snapshotCmd())
 
 	// add keybase, auxiliary RPC, query, and tx child commands
 	rootCmd.AddCommand(
@@ -247,13 +245,11 @@ func (a appCreator) newApp(logger log.Logger, db dbm.DB, traceStore io.Writer, a
 	)
 }
 
-func (a appCreator) appExport(
-	logger log.Logger, db dbm.DB, traceStore io.Writer, height int64, forZeroHeight bool, jailAllowedAddrs []string,
-	appOpts servertypes.AppOptions) (servertypes.ExportedApp, error) {
-
+// loadApp opens the app at height, or at the latest height if height is -1.
+func (a appCreator) loadApp(logger log.Logger, db dbm.DB, traceStore io.Writer, height int64, appOpts servertypes.AppOptions) (*terraapp.TerraApp, error) {
 	homePath, ok := appOpts.Get(flags.FlagHome).(string)
 	if !ok || homePath == "" {
-		return servertypes.ExportedApp{}, errors.New("application home not set")
+		return nil, errors.New("application home not set")
 	}
 
 	var terraApp *terraapp.TerraApp
@@ -261,46 +257,21 @@ func (a appCreator) appExport(
 		terraApp = terraapp.NewTerraApp(logger, db, traceStore, false, map[int64]bool{}, homePath, cast.ToUint(appOpts.Get(server.FlagInvCheckPeriod)), a.encodingConfig, appOpts, wasmconfig.DefaultConfig())
 
 		if err := terraApp.LoadHeight(height); err != nil {
-			return servertypes.ExportedApp{}, err
+			return nil, err
 		}
 	} else {
 		terraApp = terraapp.NewTerraApp(logger, db, traceStore, true, map[int64]bool{}, homePath, cast.ToUint(appOpts.Get(server.FlagInvCheckPeriod)), a.encodingConfig, appOpts, wasmconfig.DefaultConfig())
 	}
+	return terraApp, nil
+}
 
-	// export partial state with validator
-	ctx := terraApp.NewContext(true, tmproto.Header{Height: terraApp.LastBlockHeight()})
-	height = terraApp.LastBlockHeight() + 1
-
-	// run contracts first
-	bank := export.ExportContracts(terraApp)
-	bankDefaultGenesis := banktypes.DefaultGenesisState()
-	bankDefaultGenesis.Balances = bank
-
-	bankState, err := json.Marshal(bankDefaultGenesis)
-	if err != nil {
-		return servertypes.ExportedApp{}, err
-	}
-
-	genState := make(map[string]json.RawMessage)
-	genState["bank"] = bankState
-
-	// partial export
-	exportModules := []string{"wasm"}
-
-	for _, moduleName := range exportModules {
-		genState[moduleName] = terraApp.ModuleManager().Modules[moduleName].ExportGenesis(ctx, terraApp.AppCodec())
-	}
+func (a appCreator) appExport(
+	logger log.Logger, db dbm.DB, traceStore io.Writer, height int64, forZeroHeight bool, jailAllowedAddrs []string,
+	appOpts servertypes.AppOptions) (servertypes.ExportedApp, error) {
 
-	appState, err := json.MarshalIndent(genState, "", "  ")
+	terraApp, err := a.loadApp(logger, db, traceStore, height, appOpts)
 	if err != nil {
 		return servertypes.ExportedApp{}, err
 	}
-
-	validators, err := staking.WriteValidators(ctx, terraApp.StakingKeeper)
-	return servertypes.ExportedApp{
-		AppState:        appState,
-		Validators:      validators,
-		Height:          height,
-		ConsensusParams: terraApp.BaseApp.GetConsensusParams(ctx),
-	}, err
+	return terraApp.ExportAppStateAndValidators(forZeroHeight, jailAllowedAddrs)
 }
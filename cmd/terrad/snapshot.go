@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	tmjson "github.com/tendermint/tendermint/libs/json"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/server"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/cosmos/cosmos-sdk/x/staking"
+
+	terraapp "github.com/terra-money/core/app"
+	export "github.com/terra-money/core/app/export"
+	"github.com/terra-money/core/app/export/util"
+)
+
+const (
+	flagFormat       = "format"
+	flagInclude      = "include"
+	flagExclude      = "exclude"
+	flagSnapshotType = "snapshot-type"
+	flagProfile      = "profile"
+	flagTokens       = "tokens"
+	flagDryRun       = "dry-run"
+	flagWorkers      = "workers"
+	flagRefresh      = "refresh"
+	flagRefreshAll   = "refresh-all"
+	flagProvenance   = "provenance"
+	flagReproducible = "check-reproducible"
+)
+
+// snapshotResult is what the output formats are written from.
+type snapshotResult struct {
+	app         *terraapp.TerraApp
+	genesisFile string
+	snapshot    util.SnapshotBalanceAggregateMap
+}
+
+// snapshotFormats writes a snapshot result to a file named after file, or to
+// a directory with writeDir. A sha256 checksum is written next to every output.
+var snapshotFormats = map[string]struct {
+	file     string
+	write    func(w io.Writer, res snapshotResult) error
+	writeDir func(dir string, res snapshotResult) error
+}{
+	"genesis":  {file: "genesis-%d.json", write: writeGenesis},
+	"balances": {file: "balances-%d.json", write: writeBalances},
+	"csv":      {file: "balances-%d.csv", write: writeCSV},
+	"jsonl":    {file: "balances-%d.jsonl", write: writeJSONL},
+	"merkle":   {file: "merkle-%d", writeDir: writeMerkle},
+	"holders":  {file: "holders-%d", writeDir: writeHolders},
+}
+
+// snapshotCmd runs the contract export and writes the resulting balances.
+func (a appCreator) snapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "snapshot",
+		Aliases: []string{"export-snapshot"},
+		Short:   "Export the balance snapshot of users across protocols",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			serverCtx := server.GetServerContextFromCmd(cmd)
+			homeDir, _ := cmd.Flags().GetString(flags.FlagHome)
+			serverCtx.Config.SetRoot(homeDir)
+
+			formats, _ := cmd.Flags().GetStringSlice(flagFormat)
+			for _, format := range formats {
+				if _, ok := snapshotFormats[format]; !ok {
+					return fmt.Errorf("unknown output format %s, expected one of %s", format, strings.Join(snapshotFormatNames(), ", "))
+				}
+			}
+			var snapshotType util.Snapshot
+			switch s, _ := cmd.Flags().GetString(flagSnapshotType); s {
+			case "":
+			case util.PreAttack, util.PostAttack:
+				snapshotType = util.Snapshot(s)
+			default:
+				return fmt.Errorf("unknown snapshot type %s, expected %s or %s", s, util.PreAttack, util.PostAttack)
+			}
+			include, _ := cmd.Flags().GetStringSlice(flagInclude)
+			exclude, _ := cmd.Flags().GetStringSlice(flagExclude)
+			filter := export.ProtocolFilter{Include: include, Exclude: exclude}
+
+			util.UseBlockStore(serverCtx.Config.DBDir(), serverCtx.Config.DBBackend)
+			db, err := sdk.NewLevelDB("application", filepath.Join(serverCtx.Config.RootDir, "data"))
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			height, _ := cmd.Flags().GetInt64(server.FlagHeight)
+			var profile export.Profile
+			profilePath, _ := cmd.Flags().GetString(flagProfile)
+			if profilePath != "" {
+				if profile, err = export.LoadProfile(profilePath); err != nil {
+					return err
+				}
+				if !cmd.Flags().Changed(server.FlagHeight) {
+					height = profile.Height
+				} else if height != profile.Height {
+					return fmt.Errorf("--%s %d does not match the profile height %d", server.FlagHeight, height, profile.Height)
+				}
+			}
+
+			terraApp, err := a.loadApp(serverCtx.Logger, db, nil, height, serverCtx.Viper)
+			if err != nil {
+				return err
+			}
+			if profilePath == "" {
+				profile = export.ProfileFor(terraApp.LastBlockHeight())
+			}
+			if snapshotType != "" {
+				profile.SnapshotType = snapshotType
+			}
+			if cmd.Flags().Changed(flagTokens) {
+				profile.Tokens, _ = cmd.Flags().GetStringSlice(flagTokens)
+			}
+
+			if dryRun, _ := cmd.Flags().GetBool(flagDryRun); dryRun {
+				plan, err := export.BuildExportPlan(profile, filter)
+				if err != nil {
+					return err
+				}
+				return plan.Print(cmd.OutOrStdout())
+			}
+
+			workers, _ := cmd.Flags().GetInt(flagWorkers)
+			refresh, _ := cmd.Flags().GetStringSlice(flagRefresh)
+			refreshAll, _ := cmd.Flags().GetBool(flagRefreshAll)
+			provenance, _ := cmd.Flags().GetBool(flagProvenance)
+			opts := export.Options{
+				Profile:    profile,
+				Filter:     filter,
+				Workers:    workers,
+				Refresh:    refresh,
+				RefreshAll: refreshAll,
+				Provenance: provenance,
+			}
+			res := snapshotResult{
+				app:         terraApp,
+				genesisFile: serverCtx.Config.GenesisFile(),
+				snapshot:    export.ExportContracts(terraApp, opts),
+			}
+
+			outputDir, _ := cmd.Flags().GetString(flagOutputDir)
+			if err := os.MkdirAll(outputDir, 0777); err != nil {
+				return err
+			}
+			for _, format := range formats {
+				f := snapshotFormats[format]
+				path := filepath.Join(outputDir, fmt.Sprintf(f.file, terraApp.LastBlockHeight()))
+				var err error
+				if f.writeDir != nil {
+					err = f.writeDir(path, res)
+				} else {
+					err = util.WriteWithChecksum(path, func(w io.Writer) error {
+						return f.write(w, res)
+					})
+				}
+				if err != nil {
+					return fmt.Errorf("writing %s output: %v", format, err)
+				}
+				cmd.PrintErrf("wrote %s\n", path)
+			}
+
+			if reproducible, _ := cmd.Flags().GetBool(flagReproducible); reproducible {
+				sums, err := outputSums(formats, res)
+				if err != nil {
+					return err
+				}
+				// the rerun opens the application db itself
+				if err := db.Close(); err != nil {
+					return err
+				}
+				if err := checkReproducible(cmd, sums, terraApp.LastBlockHeight()); err != nil {
+					return err
+				}
+				cmd.PrintErrln("export is reproducible")
+			}
+			return nil
+		},
+	}
+
+	cmd.PersistentFlags().Int64(server.FlagHeight, -1, "Snapshot height (-1 means latest height)")
+	cmd.Flags().String(flagOutputDir, ".", "Directory the snapshot outputs are written to")
+	cmd.Flags().StringSlice(flagFormat, []string{"genesis"}, fmt.Sprintf("Output formats, any of %s", strings.Join(snapshotFormatNames(), ", ")))
+	cmd.Flags().StringSlice(flagInclude, nil, "Only run these protocol exporters")
+	cmd.Flags().StringSlice(flagExclude, nil, "Skip these protocol exporters")
+	cmd.Flags().String(flagProfile, "", "YAML or JSON snapshot profile (a built-in profile for the height by default)")
+	cmd.Flags().StringSlice(flagTokens, nil, "cw20 tokens to snapshot as denoms of their own (overrides the profile)")
+	cmd.Flags().String(flagSnapshotType, "", fmt.Sprintf("Snapshot type, %s or %s (overrides the profile)", util.PreAttack, util.PostAttack))
+	cmd.Flags().Bool(flagDryRun, false, "Print the resolved contract export plan and exit")
+	cmd.Flags().Int(flagWorkers, runtime.NumCPU(), "Number of protocol exporters to run concurrently")
+	cmd.Flags().StringSlice(flagRefresh, nil, "Re-run the named exporters instead of using their cached outputs")
+	cmd.Flags().Bool(flagRefreshAll, false, "Ignore all cached exporter outputs")
+	cmd.Flags().Bool(flagProvenance, false, "Record the sources of every balance in the saved snapshots")
+	cmd.Flags().Bool(flagReproducible, false, "Run the export a second time in a fresh process against the same cache and fail unless the outputs are identical")
+
+	cmd.AddCommand(explainCmd(), verifyProofCmd(), diffCmd())
+	return cmd
+}
+
+func snapshotFormatNames() []string {
+	var names []string
+	for name := range snapshotFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outputSums returns the sha256 of res written in every file format of formats
+// and as bank balances.
+func outputSums(formats []string, res snapshotResult) (map[string][]byte, error) {
+	sums := make(map[string][]byte)
+	for _, format := range append([]string{"balances"}, formats...) {
+		f := snapshotFormats[format]
+		if f.write == nil {
+			continue
+		}
+		h := sha256.New()
+		if err := f.write(h, res); err != nil {
+			return nil, err
+		}
+		sums[format] = h.Sum(nil)
+	}
+	return sums, nil
+}
+
+// checkReproducible reruns cmd in a fresh process, so no state of the first
+// run carries over, writing the formats of sums to a temporary directory. It
+// fails unless every output matches its sum.
+func checkReproducible(cmd *cobra.Command, sums map[string][]byte, height int64) error {
+	dir, err := os.MkdirTemp("", "snapshot-rerun")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var formats []string
+	for format := range sums {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	args := append(strings.Fields(cmd.CommandPath())[1:],
+		"--"+flagOutputDir, dir, "--"+flagFormat, strings.Join(formats, ","))
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		switch f.Name {
+		case flagReproducible, flagOutputDir, flagFormat:
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range slice.GetSlice() {
+				args = append(args, fmt.Sprintf("--%s=%s", f.Name, v))
+			}
+			return
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+	})
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	rerun := exec.Command(exe, args...)
+	rerun.Stdout, rerun.Stderr = cmd.ErrOrStderr(), cmd.ErrOrStderr()
+	if err := rerun.Run(); err != nil {
+		return fmt.Errorf("rerunning the export: %v", err)
+	}
+
+	for _, format := range formats {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf(snapshotFormats[format].file, height)))
+		if err != nil {
+			return err
+		}
+		if sum := sha256.Sum256(data); !bytes.Equal(sum[:], sums[format]) {
+			return fmt.Errorf("%s output differs between two runs of the export", format)
+		}
+	}
+	return nil
+}
+
+// writeGenesis writes a genesis holding the snapshot as bank balances, along
+// with the wasm state.
+func writeGenesis(w io.Writer, res snapshotResult) error {
+	terraApp := res.app
+	ctx := terraApp.NewContext(true, tmproto.Header{Height: terraApp.LastBlockHeight()})
+
+	bankGenesis := banktypes.DefaultGenesisState()
+	balances, err := res.snapshot.ExportToBalances()
+	if err != nil {
+		return err
+	}
+	bankGenesis.Balances = balances
+	bankState, err := json.Marshal(bankGenesis)
+	if err != nil {
+		return err
+	}
+
+	genState := make(map[string]json.RawMessage)
+	genState["bank"] = bankState
+	// partial export
+	for _, moduleName := range []string{"wasm"} {
+		genState[moduleName] = terraApp.ModuleManager().Modules[moduleName].ExportGenesis(ctx, terraApp.AppCodec())
+	}
+	appState, err := json.MarshalIndent(genState, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	validators, err := staking.WriteValidators(ctx, terraApp.StakingKeeper)
+	if err != nil {
+		return err
+	}
+	consensusParams := terraApp.BaseApp.GetConsensusParams(ctx)
+
+	doc, err := tmtypes.GenesisDocFromFile(res.genesisFile)
+	if err != nil {
+		return err
+	}
+	doc.AppState = appState
+	doc.Validators = validators
+	doc.InitialHeight = terraApp.LastBlockHeight() + 1
+	doc.ConsensusParams = &tmproto.ConsensusParams{
+		Block: tmproto.BlockParams{
+			MaxBytes:   consensusParams.Block.MaxBytes,
+			MaxGas:     consensusParams.Block.MaxGas,
+			TimeIotaMs: doc.ConsensusParams.Block.TimeIotaMs,
+		},
+		Evidence: tmproto.EvidenceParams{
+			MaxAgeNumBlocks: consensusParams.Evidence.MaxAgeNumBlocks,
+			MaxAgeDuration:  consensusParams.Evidence.MaxAgeDuration,
+			MaxBytes:        consensusParams.Evidence.MaxBytes,
+		},
+		Validator: tmproto.ValidatorParams{
+			PubKeyTypes: consensusParams.Validator.PubKeyTypes,
+		},
+	}
+
+	encoded, err := tmjson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(sdk.MustSortJSON(encoded))
+	return err
+}
+
+// writeBalances writes the snapshot as a JSON list of bank balances.
+func writeBalances(w io.Writer, res snapshotResult) error {
+	balances, err := res.snapshot.ExportToBalances()
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(balances, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// writeCSV streams the snapshot as address,denom,amount lines sorted by address.
+func writeCSV(w io.Writer, res snapshotResult) error {
+	return util.WriteCSV(w, res.snapshot)
+}
+
+// writeJSONL streams the snapshot as one bank balance per line sorted by address.
+func writeJSONL(w io.Writer, res snapshotResult) error {
+	return util.WriteJSONL(w, res.snapshot)
+}
+
+// writeMerkle writes the merkle root and the claim proofs of every denom.
+func writeMerkle(dir string, res snapshotResult) error {
+	return util.WriteMerkleAirdrop(dir, res.snapshot)
+}
+
+// writeHolders writes one address,amount file per cw20 tracked by the profile.
+func writeHolders(dir string, res snapshotResult) error {
+	return util.WriteTokenHolders(dir, res.snapshot)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/terra-money/core/app/export/util"
+)
+
+const flagRoot = "root"
+
+func verifyProofCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify-proof [proof-file]",
+		Short: "Check a merkle airdrop claim against its root offline",
+		Long: `Check a merkle airdrop claim against its root offline. The proof file is one
+written by the merkle output format. Without --root, the root is read from the
+root.json of the denom the proof file belongs to.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var proof util.MerkleProof
+			if err := json.Unmarshal(data, &proof); err != nil {
+				return fmt.Errorf("invalid proof file %s: %v", args[0], err)
+			}
+			if proof.Amount.IsNil() {
+				return fmt.Errorf("proof file %s has no amount", args[0])
+			}
+
+			root, _ := cmd.Flags().GetString(flagRoot)
+			if root == "" {
+				rootFile := filepath.Join(filepath.Dir(args[0]), "..", "root.json")
+				data, err := os.ReadFile(rootFile)
+				if err != nil {
+					return fmt.Errorf("no --%s given and %v", flagRoot, err)
+				}
+				var r util.MerkleRoot
+				if err := json.Unmarshal(data, &r); err != nil {
+					return fmt.Errorf("invalid root file %s: %v", rootFile, err)
+				}
+				if r.Denom != proof.Denom {
+					return fmt.Errorf("root file %s is for %s, the proof is for %s", rootFile, r.Denom, proof.Denom)
+				}
+				root = r.MerkleRoot
+			}
+
+			if err := util.VerifyMerkleProof(root, proof.Address, proof.Amount, proof.Proof); err != nil {
+				return err
+			}
+			cmd.Printf("valid claim of %s %s by %s under root %s\n", proof.Amount, proof.Denom, proof.Address, root)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagRoot, "", "Hex encoded merkle root to verify against")
+	return cmd
+}